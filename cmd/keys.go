@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+type keysCmd struct {
+	Mint struct {
+		Client string   `arg:"" help:"Name of the client the key is for."`
+		Scopes []string `name:"scope" default:"bot" help:"Scopes or roles (bot, moderator, admin) to give the key."`
+	} `cmd:"" help:"Create an API key. It is only ever shown once."`
+	List   struct{} `cmd:"" help:"List API keys and when they were last used."`
+	Revoke struct {
+		Prefix string `arg:"" help:"Prefix of the key to revoke, as shown by keys list."`
+	} `cmd:"" help:"Revoke an API key."`
+}
+
+func runKeys(ctx context.Context, command string, cmd keysCmd, store *db.Postgres) (err error) {
+	ctx, span := o11y.StartSpan(ctx, "main: keys")
+	defer o11y.End(span, &err)
+	o11y.AddField(ctx, "command", command)
+
+	switch {
+	case strings.HasPrefix(command, "keys mint"):
+		scopes, err := db.ParseScopes(cmd.Mint.Scopes)
+		if err != nil {
+			return err
+		}
+		apiKey, key, err := store.CreateAPIKey(ctx, cmd.Mint.Client, scopes)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created key %s for %s with %s, it will not be shown again:\n%s\n", apiKey.Prefix, apiKey.Client, joinScopes(apiKey.Scopes), key)
+	case command == "keys list":
+		keys, err := store.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			fmt.Println("there are no api keys")
+		}
+		for _, k := range keys {
+			state := "never used"
+			if k.LastUsedAt != nil {
+				state = "last used " + k.LastUsedAt.Format(time.RFC3339)
+			}
+			if k.RevokedAt != nil {
+				state = "revoked " + k.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\tcreated %s\t%s\n", k.Prefix, k.Client, joinScopes(k.Scopes), k.CreatedAt.Format(time.RFC3339), state)
+		}
+	case strings.HasPrefix(command, "keys revoke"):
+		err := store.RevokeAPIKey(ctx, cmd.Revoke.Prefix)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("revoked %s\n", cmd.Revoke.Prefix)
+	default:
+		return fmt.Errorf("unknown keys command: %s", command)
+	}
+	return nil
+}
+
+func joinScopes(scopes []db.Scope) string {
+	if len(scopes) == 0 {
+		return "no scopes"
+	}
+	s := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		s = append(s, string(scope))
+	}
+	return strings.Join(s, ",")
+}
+
+// readKeysFile reads a file with a line for each key of client=key followed
+// by the scopes or roles it has, separated by spaces, into a map of each key
+// to the client it belongs to and what it can do. Keys without any scopes
+// have the bot role. Blank lines and lines starting with # are skipped. name is what the file is called in errors.
+func readKeysFile(path string, name string) (map[string]db.APIKey, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error opening the %s file: %w", name, err)
+	}
+	defer f.Close()
+
+	keys := map[string]db.APIKey{}
+	// A key given twice would quietly keep only one of its clients, so it
+	// is turned away instead.
+	seen := map[string]int{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		client, rest, ok := strings.Cut(text, "=")
+		client = strings.TrimSpace(client)
+		if !ok || client == "" {
+			return nil, fmt.Errorf("%w: line %d of the %s file is not client=key", db.ErrInvalidInput, line, name)
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("%w: line %d of the %s file has no key", db.ErrInvalidInput, line, name)
+		}
+		if first, ok := seen[fields[0]]; ok {
+			return nil, fmt.Errorf("%w: line %d of the %s file has the same key as line %d", db.ErrInvalidInput, line, name, first)
+		}
+		seen[fields[0]] = line
+		// Lines from before keys had scopes get the least a key can have
+		// and still be of use.
+		names := fields[1:]
+		if len(names) == 0 {
+			names = []string{"bot"}
+		}
+		scopes, err := db.ParseScopes(names)
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the %s file: %w", line, name, err)
+		}
+		keys[fields[0]] = db.APIKey{Client: client, Scopes: scopes}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("there was an error reading the %s file: %w", name, err)
+	}
+	return keys, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestReadKeysFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys")
+	err := os.WriteFile(path, []byte("# the discord bot\ndiscord-bot = bot-key bot write:score\n\ndashboard=dashboard-key\n"), 0o600)
+	assert.NilError(t, err)
+
+	keys, err := readKeysFile(path, "api keys")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(keys, map[string]db.APIKey{
+		"bot-key":       {Client: "discord-bot", Scopes: []db.Scope{db.ScopeGamePlay, db.ScopeReadLeaderboard, db.ScopeWriteScore}},
+		"dashboard-key": {Client: "dashboard", Scopes: []db.Scope{db.ScopeGamePlay, db.ScopeReadLeaderboard}},
+	}))
+
+	for _, contents := range []string{
+		"bot-key\n",
+		"discord-bot=\n",
+		"discord-bot=bot-key superuser\n",
+		"discord-bot=bot-key\ndashboard=bot-key\n",
+		"discord-bot=bot-key\ndiscord-bot=bot-key\n",
+	} {
+		err = os.WriteFile(path, []byte(contents), 0o600)
+		assert.NilError(t, err)
+		_, err = readKeysFile(path, "api keys")
+		assert.Check(t, errors.Is(err, db.ErrInvalidInput), "%q got: %v", contents, err)
+	}
+}
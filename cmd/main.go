@@ -5,16 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/circleci/ex/httpserver"
 	"github.com/circleci/ex/httpserver/healthcheck"
 	"github.com/circleci/ex/termination"
+	"github.com/imlogang/api-service/cmd/setup"
 	"github.com/imlogang/api-service/internal/db"
+	"github.com/imlogang/api-service/internal/games"
 	"github.com/imlogang/api-service/internal/internalapi"
-	"github.com/imlogang/api-service/cmd/setup"
-	"github.com/jackc/pgx/v5"
 
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/system"
@@ -29,6 +30,38 @@ type cli struct {
 	APIAddr            string        `long:"internal-addr" default:":8080" description:"internal addr"`
 	HealthcheckAPIAddr string        `long:"internal-addr" default:":8081" description:"internal addr for healthchecks"`
 	ShutdownDelay      time.Duration `long:"shutdown-delay" default:"30s" description:"shutdown delay"`
+
+	DBMaxConns          int32         `long:"db-max-conns" default:"10" description:"maximum number of pooled database connections"`
+	DBMinConns          int32         `long:"db-min-conns" default:"2" description:"minimum number of idle database connections kept open"`
+	DBHealthCheckPeriod time.Duration `long:"db-health-check-period" default:"1m" description:"how often idle database connections are health checked"`
+	DBMaxConnIdleTime   time.Duration `long:"db-max-conn-idle-time" default:"30m" description:"how long a database connection may sit idle before it is closed"`
+
+	RoundTimeout     time.Duration `long:"round-timeout" default:"5m" description:"how long a game round runs before it expires"`
+	RoundPoints      int           `long:"round-points" default:"5" description:"points awarded for solving a game round without hints"`
+	HintPenalty      int           `long:"hint-penalty" default:"1" description:"points taken off a solved round for each hint used"`
+	GuessTolerance   int           `long:"guess-tolerance" default:"0" description:"how many letters a guess can be off by and still be correct"`
+	GuessCloseWithin int           `long:"guess-close-within" default:"2" description:"how many letters a wrong guess can be off by and still be called close"`
+
+	CatalogStore string `long:"catalog-store" default:"postgres" enum:"postgres,file" description:"where the pokemon catalog is kept"`
+	CatalogFile  string `long:"catalog-file" default:"pokemon-catalog.json" description:"path of the pokemon catalog when it is kept on disk"`
+	CatalogSeed  string `long:"catalog-seed" default:"" description:"json or csv file to seed an empty pokemon catalog from, instead of the bundled one"`
+	CatalogWarm  bool   `long:"catalog-warm" description:"seed an empty pokemon catalog from PokeAPI"`
+
+	AdminToken  string   `long:"admin-token" env:"ADMIN_TOKEN" description:"bearer token for the routes that create and archive tables, which are turned off without one"`
+	AdminTables []string `long:"admin-tables" env:"ADMIN_TABLES" description:"tables the admin routes can manage, a trailing * matches a prefix"`
+
+	APIKeysFile string `long:"api-keys-file" env:"API_KEYS_FILE" description:"file of client=key lines, each followed by the key's scopes or roles, accepted alongside the keys made with keys mint; keys without any get the bot role"`
+
+	SigningKeysFile string        `long:"signing-keys-file" env:"SIGNING_KEYS_FILE" description:"file of client=secret lines for clients that sign their requests instead of sending a key"`
+	SigningMaxSkew  time.Duration `long:"signing-max-skew" default:"5m" description:"how far a signed request's timestamp can be from the server's clock"`
+
+	RateLimitDefault string   `long:"rate-limit-default" env:"RATE_LIMIT_DEFAULT" description:"requests/period[:burst] each client can make to a route without a limit of its own, unlimited when empty"`
+	RateLimits       []string `long:"rate-limit" env:"RATE_LIMITS" description:"route=requests/period[:burst] limits for single routes, such as get_pokemon=10/m"`
+	RateLimitPerUser []string `long:"rate-limit-per-user" env:"RATE_LIMIT_PER_USER" description:"routes whose limit applies to each username a client sends rather than the client as a whole"`
+
+	Serve   struct{}   `cmd:"" default:"1" help:"Run the api service."`
+	Migrate migrateCmd `cmd:"" help:"Manage the database schema."`
+	Keys    keysCmd    `cmd:"" help:"Manage the API keys clients authenticate with."`
 }
 
 func main() {
@@ -41,7 +74,7 @@ func main() {
 
 func run(ctx context.Context, version, date string) (err error) {
 	cli := cli{}
-	kong.Parse(&cli)
+	kctx := kong.Parse(&cli)
 	cfg := setup.O11ySetup()
 	ctx, o11yCleanup, err := setup.LoadO11y(ctx, "internal-service", *cfg, version)
 	if err != nil {
@@ -49,7 +82,23 @@ func run(ctx context.Context, version, date string) (err error) {
 	}
 	defer o11yCleanup(ctx)
 
-	testDatabase(ctx)
+	store, err := loadStore(ctx, cli)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if strings.HasPrefix(kctx.Command(), "migrate") {
+		return runMigrate(ctx, kctx.Command(), cli.Migrate, store)
+	}
+	if strings.HasPrefix(kctx.Command(), "keys") {
+		return runKeys(ctx, kctx.Command(), cli.Keys, store)
+	}
+
+	err = testDatabase(ctx, store)
+	if err != nil {
+		return err
+	}
 
 	ctx, runSpan := o11y.StartSpan(ctx, "main: run")
 	defer o11y.End(runSpan, &err)
@@ -57,7 +106,7 @@ func run(ctx context.Context, version, date string) (err error) {
 	sys := system.New()
 	defer sys.Cleanup(ctx)
 
-	err = loadInternal(ctx, cli, sys)
+	err = loadInternal(ctx, cli, sys, store)
 	if err != nil {
 		return err
 	}
@@ -72,8 +121,120 @@ func run(ctx context.Context, version, date string) (err error) {
 	return sys.Run(ctx, 0)
 }
 
-func loadInternal(ctx context.Context, cli cli, sys *system.System) error {
-	a, err := httpapi.New(ctx)
+func loadStore(ctx context.Context, cli cli) (*db.Postgres, error) {
+	config := db.LoadConfig()
+	config.MaxConns = cli.DBMaxConns
+	config.MinConns = cli.DBMinConns
+	config.HealthCheckPeriod = cli.DBHealthCheckPeriod
+	config.MaxConnIdleTime = cli.DBMaxConnIdleTime
+
+	return db.NewPostgres(ctx, config)
+}
+
+func loadCatalog(ctx context.Context, cli cli, store *db.Postgres) (*games.MemoryCatalog, error) {
+	var catalogStore db.CatalogStore = store
+	if cli.CatalogStore == "file" {
+		catalogStore = games.FileCatalogStore{Path: cli.CatalogFile}
+	}
+
+	return games.LoadCatalog(ctx, catalogStore, games.CatalogOptions{
+		SeedFile:        cli.CatalogSeed,
+		WarmFromPokeAPI: cli.CatalogWarm,
+	})
+}
+
+// loadKeys accepts the keys in Postgres, and those in the API keys file if
+// one is given.
+func loadKeys(cli cli, store *db.Postgres) (db.APIKeyStore, error) {
+	if cli.APIKeysFile == "" {
+		return store, nil
+	}
+	keys, err := readKeysFile(cli.APIKeysFile, "api keys")
+	if err != nil {
+		return nil, err
+	}
+	static, err := db.NewStaticAPIKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	return db.APIKeyStores{static, store}, nil
+}
+
+// loadRateLimits parses the rate limit flags. Requests are limited in each
+// replica's memory.
+func loadRateLimits(cli cli) (httpapi.RateLimitOptions, error) {
+	opts := httpapi.RateLimitOptions{
+		Limiter: httpapi.NewMemoryRateLimiter(),
+		Routes:  map[string]httpapi.RateLimit{},
+		PerUser: cli.RateLimitPerUser,
+	}
+	var err error
+	if cli.RateLimitDefault != "" {
+		opts.Default, err = httpapi.ParseRateLimit(cli.RateLimitDefault)
+		if err != nil {
+			return httpapi.RateLimitOptions{}, err
+		}
+	}
+	for _, limit := range cli.RateLimits {
+		route, rate, ok := strings.Cut(limit, "=")
+		if !ok {
+			return httpapi.RateLimitOptions{}, fmt.Errorf("the rate limit %q must be route=requests/period", limit)
+		}
+		opts.Routes[route], err = httpapi.ParseRateLimit(rate)
+		if err != nil {
+			return httpapi.RateLimitOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+func loadInternal(ctx context.Context, cli cli, sys *system.System, store *db.Postgres) error {
+	catalog, err := loadCatalog(ctx, cli, store)
+	if err != nil {
+		return err
+	}
+	keys, err := loadKeys(cli, store)
+	if err != nil {
+		return err
+	}
+	signing := httpapi.SigningOptions{MaxClockSkew: cli.SigningMaxSkew}
+	if cli.SigningKeysFile != "" {
+		secrets, err := readKeysFile(cli.SigningKeysFile, "signing keys")
+		if err != nil {
+			return err
+		}
+		signing.Secrets, err = httpapi.NewSigningSecrets(secrets)
+		if err != nil {
+			return err
+		}
+	}
+
+	rateLimits, err := loadRateLimits(cli)
+	if err != nil {
+		return err
+	}
+
+	a, err := httpapi.New(ctx, httpapi.Options{
+		Store: store,
+		Game: games.NewGame(store, games.GameConfig{
+			RoundTimeout: cli.RoundTimeout,
+			Points:       cli.RoundPoints,
+			HintPenalty:  cli.HintPenalty,
+			Matcher: games.Matcher{
+				Tolerance:   cli.GuessTolerance,
+				CloseWithin: cli.GuessCloseWithin,
+			},
+			Catalog: catalog,
+		}),
+		Catalog:    catalog,
+		Keys:       keys,
+		Signing:    signing,
+		RateLimits: rateLimits,
+		Admin: httpapi.AdminOptions{
+			Token:  cli.AdminToken,
+			Tables: cli.AdminTables,
+		},
+	})
 	if err != nil {
 		return err
 	}
@@ -92,62 +253,27 @@ func loadInternal(ctx context.Context, cli cli, sys *system.System) error {
 	return err
 }
 
-func testDatabase(ctx context.Context) {
+// testDatabase checks the database can be reached and brings its schema up
+// to date. The service is not started when either fails, since every route
+// needs the current schema.
+func testDatabase(ctx context.Context, store *db.Postgres) (err error) {
 	ctx, span := o11y.StartSpan(ctx, "Database Check")
-	defer span.End()
+	defer o11y.End(span, &err)
 
-	config := db.LoadConfig()
-	_, err := config.TestDBConnection(ctx)
+	err = store.Ping(ctx)
 	if err != nil {
-		databaseError := fmt.Sprintf("database error: %s", err)
-		o11y.AddFieldToTrace(ctx, "db-check", databaseError)
 		o11y.AddFieldToTrace(ctx, "status", "unhealthy")
-		return
+		return fmt.Errorf("database error: %w", err)
 	}
 
-	err = ensurePokemonScoresTable(ctx)
+	applied, err := store.MigrateUp(ctx)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "status", "schema_error")
-		o11y.AddFieldToTrace(ctx, "error", err.Error())
-		return
+		return fmt.Errorf("there was an error migrating the database: %w", err)
 	}
 
+	o11y.AddFieldToTrace(ctx, "migrations-applied", len(applied))
 	o11y.AddFieldToTrace(ctx, "db-check", "healthy")
 	o11y.AddFieldToTrace(ctx, "status", "healthy")
-}
-
-func ensurePokemonScoresTable(ctx context.Context) (err error) {
-	config := db.LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		return err
-	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	ctx, span := o11y.StartSpan(ctx, "db.ensure_pokemon_scores")
-	defer o11y.End(span, &err)
-
-	_, err = DB.Exec(ctx, `
-		CREATE TABLE IF NOT EXISTS pokemon_scores (
-			id SERIAL PRIMARY KEY
-		);
-
-		ALTER TABLE pokemon_scores
-			ADD COLUMN IF NOT EXISTS username TEXT UNIQUE,
-			ADD COLUMN IF NOT EXISTS score INTEGER NOT NULL DEFAULT 0;
-	`)
-	if err != nil {
-		o11y.AddFieldToTrace(ctx, "table", "pokemon_scores")
-		o11y.AddFieldToTrace(ctx, "error", err.Error())
-		return err
-	}
-
-	o11y.AddFieldToTrace(ctx, "table", "pokemon_scores")
-	o11y.AddFieldToTrace(ctx, "status", "ensured")
 	return nil
 }
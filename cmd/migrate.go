@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+type migrateCmd struct {
+	Up   struct{} `cmd:"" help:"Apply every pending migration."`
+	Down struct {
+		Steps int `default:"1" help:"Number of migrations to roll back."`
+	} `cmd:"" help:"Roll back the most recently applied migrations."`
+	Status struct{} `cmd:"" help:"List migrations and whether they have been applied."`
+}
+
+func runMigrate(ctx context.Context, command string, cmd migrateCmd, store *db.Postgres) (err error) {
+	ctx, span := o11y.StartSpan(ctx, "main: migrate")
+	defer o11y.End(span, &err)
+	o11y.AddField(ctx, "command", command)
+
+	switch command {
+	case "migrate up":
+		applied, err := store.MigrateUp(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("the schema is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+	case "migrate down":
+		reverted, err := store.MigrateDown(ctx, cmd.Down.Steps)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("there are no applied migrations to roll back")
+		}
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+	case "migrate status":
+		status, err := store.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command: %s", command)
+	}
+	return nil
+}
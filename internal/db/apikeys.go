@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// APIKey is a key a client uses to call the API. The key itself is only
+// ever seen when it is created; after that it is known by its Prefix.
+type APIKey struct {
+	ID         int64
+	Client     string
+	Prefix     string
+	Scopes     []Scope
+	CreatedAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (k APIKey) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, scope)
+}
+
+// Scope is something an API key is allowed to do.
+type Scope string
+
+const (
+	ScopeReadLeaderboard Scope = "read:leaderboard"
+	ScopeWriteScore      Scope = "write:score"
+	ScopeAdminTables     Scope = "admin:tables"
+	ScopeGamePlay        Scope = "game:play"
+)
+
+// Roles are named sets of scopes to give keys.
+var Roles = map[string][]Scope{
+	"bot":       {ScopeReadLeaderboard, ScopeGamePlay},
+	"moderator": {ScopeReadLeaderboard, ScopeGamePlay, ScopeWriteScore},
+	"admin":     {ScopeReadLeaderboard, ScopeGamePlay, ScopeWriteScore, ScopeAdminTables},
+}
+
+// ParseScopes turns a list of scopes and roles into the scopes they give,
+// sorted and without repeats.
+func ParseScopes(names []string) ([]Scope, error) {
+	var scopes []Scope
+	for _, name := range names {
+		if role, ok := Roles[name]; ok {
+			scopes = append(scopes, role...)
+			continue
+		}
+		switch scope := Scope(name); scope {
+		case ScopeReadLeaderboard, ScopeWriteScore, ScopeAdminTables, ScopeGamePlay:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("%w: %q is not a scope or a role", ErrInvalidInput, name)
+		}
+	}
+	slices.Sort(scopes)
+	return slices.Compact(scopes), nil
+}
+
+func scopeStrings(scopes []Scope) []string {
+	s := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		s = append(s, string(scope))
+	}
+	return s
+}
+
+func stringScopes(s []string) []Scope {
+	scopes := make([]Scope, 0, len(s))
+	for _, scope := range s {
+		scopes = append(scopes, Scope(scope))
+	}
+	return scopes
+}
+
+// APIKeyStore finds the key a client sent. Unknown and revoked keys are
+// ErrNotFound.
+type APIKeyStore interface {
+	LookupAPIKey(ctx context.Context, key string) (APIKey, error)
+}
+
+// APIKeyStores looks a key up in each store in turn.
+type APIKeyStores []APIKeyStore
+
+func (s APIKeyStores) LookupAPIKey(ctx context.Context, key string) (APIKey, error) {
+	for _, store := range s {
+		apiKey, err := store.LookupAPIKey(ctx, key)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		return apiKey, err
+	}
+	return APIKey{}, errInvalidAPIKey
+}
+
+var errInvalidAPIKey = fmt.Errorf("the api key is not valid: %w", ErrNotFound)
+
+// Keys made by CreateAPIKey look like ak_<prefix>_<secret>.
+const (
+	apiKeyScheme       = "ak"
+	apiKeyPrefixBytes  = 6
+	apiKeySecretBytes  = 32
+	staticAPIKeyPrefix = "static"
+)
+
+func newAPIKey() (prefix string, key string, err error) {
+	b := make([]byte, apiKeyPrefixBytes+apiKeySecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("there was an error generating the api key: %w", err)
+	}
+	prefix = hex.EncodeToString(b[:apiKeyPrefixBytes])
+	return prefix, apiKeyScheme + "_" + prefix + "_" + hex.EncodeToString(b[apiKeyPrefixBytes:]), nil
+}
+
+// apiKeyPrefix returns the prefix of a key made by CreateAPIKey.
+func apiKeyPrefix(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyScheme || len(parts[1]) != 2*apiKeyPrefixBytes {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// hashAPIKey hashes a key for storing. Keys are random enough that a fast,
+// unsalted hash is all that is needed.
+func hashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// CreateAPIKey makes a new key for client with scopes. The key is returned
+// alongside the record of it and can not be read back later.
+func (p *Postgres) CreateAPIKey(ctx context.Context, client string, scopes []Scope) (APIKey, string, error) {
+	if client == "" {
+		return APIKey{}, "", fmt.Errorf("%w: the client must not be empty", ErrInvalidInput)
+	}
+	prefix, key, err := newAPIKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	apiKey := APIKey{Client: client, Prefix: prefix, Scopes: scopes}
+	err = p.pool.QueryRow(ctx, `
+		INSERT INTO api_keys (client, prefix, hash, scopes) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`,
+		client, prefix, hashAPIKey(key), scopeStrings(scopes),
+	).Scan(&apiKey.ID, &apiKey.CreatedAt)
+	if err != nil {
+		return APIKey{}, "", fmt.Errorf("there was an error creating the api key: %w", wrapPgError(err))
+	}
+	return apiKey, key, nil
+}
+
+// ListAPIKeys returns every key, revoked ones included, oldest first.
+func (p *Postgres) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, client, prefix, scopes, created_at, last_used_at, revoked_at
+		FROM api_keys
+		ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error listing the api keys: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var k APIKey
+		var scopes []string
+		err := rows.Scan(&k.ID, &k.Client, &k.Prefix, &scopes, &k.CreatedAt, &k.LastUsedAt, &k.RevokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		k.Scopes = stringScopes(scopes)
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey stops the key with prefix from working. Revoked keys are kept
+// so they still show up in ListAPIKeys.
+func (p *Postgres) RevokeAPIKey(ctx context.Context, prefix string) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE api_keys SET revoked_at = now() WHERE prefix = $1 AND revoked_at IS NULL`, prefix)
+	if err != nil {
+		return fmt.Errorf("there was an error revoking the api key: %w", wrapPgError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("there is no active api key %s: %w", prefix, ErrNotFound)
+	}
+	return nil
+}
+
+// LookupAPIKey finds the key a client sent and notes that it has been used.
+func (p *Postgres) LookupAPIKey(ctx context.Context, key string) (APIKey, error) {
+	prefix, ok := apiKeyPrefix(key)
+	if !ok {
+		return APIKey{}, errInvalidAPIKey
+	}
+
+	var apiKey APIKey
+	var scopes []string
+	err := p.pool.QueryRow(ctx, `
+		UPDATE api_keys SET last_used_at = now()
+		WHERE prefix = $1 AND hash = $2 AND revoked_at IS NULL
+		RETURNING id, client, prefix, scopes, created_at, last_used_at`,
+		prefix, hashAPIKey(key),
+	).Scan(&apiKey.ID, &apiKey.Client, &apiKey.Prefix, &scopes, &apiKey.CreatedAt, &apiKey.LastUsedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIKey{}, errInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("there was an error looking up the api key: %w", wrapPgError(err))
+	}
+	apiKey.Scopes = stringScopes(scopes)
+	return apiKey, nil
+}
+
+// StaticAPIKeys are keys given to the service up front rather than made
+// with CreateAPIKey, such as ones kept in a Kubernetes secret. They are held
+// by the hash of the key.
+type StaticAPIKeys map[string]APIKey
+
+// NewStaticAPIKeys takes a map of keys to the client each belongs to and
+// what it can do.
+func NewStaticAPIKeys(keys map[string]APIKey) (StaticAPIKeys, error) {
+	s := StaticAPIKeys{}
+	for key, apiKey := range keys {
+		if key == "" || apiKey.Client == "" {
+			return nil, fmt.Errorf("%w: an api key and its client must not be empty", ErrInvalidInput)
+		}
+		apiKey.Prefix = staticAPIKeyPrefix
+		s[hex.EncodeToString(hashAPIKey(key))] = apiKey
+	}
+	return s, nil
+}
+
+func (s StaticAPIKeys) LookupAPIKey(_ context.Context, key string) (APIKey, error) {
+	apiKey, ok := s[hex.EncodeToString(hashAPIKey(key))]
+	if !ok {
+		return APIKey{}, errInvalidAPIKey
+	}
+	return apiKey, nil
+}
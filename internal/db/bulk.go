@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ReasonImport is the reason logged for scores changed by ImportScores.
+const ReasonImport = "import"
+
+// maxUsernameLength is the size of the username column the score tables had
+// before the schema was fixed, which is still enforced.
+const maxUsernameLength = 255
+
+// AllGuilds is passed to ExportScores and ImportScores in place of a guild
+// to work on every guild at once. Callers must only allow it to admins.
+const AllGuilds = "*"
+
+// ScoreRow is one user's score in a bulk import or export. Line is where an
+// imported row came from, so errors can point back at it.
+type ScoreRow struct {
+	Line     int
+	GuildID  string
+	Username string
+	Score    int
+}
+
+// RowError is why one row of an import was skipped.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e RowError) Unwrap() error {
+	return e.Err
+}
+
+type ImportResult struct {
+	Imported int
+	// Errors are the rows that were skipped. The rest are still imported.
+	Errors []RowError
+}
+
+// validateScoreRows splits rows into those that can be imported into guildID
+// and errors for the rest. A user listed twice keeps their first row.
+func validateScoreRows(rows []ScoreRow, guildID string) ([]ScoreRow, []RowError) {
+	var valid []ScoreRow
+	var errs []RowError
+	seen := map[member]int{}
+	for _, row := range rows {
+		key := member{row.GuildID, row.Username}
+		switch {
+		case guildID != AllGuilds && row.GuildID != guildID:
+			errs = append(errs, RowError{Line: row.Line, Err: fmt.Errorf("%w: the row is for guild %q, not %q", ErrInvalidInput, row.GuildID, guildID)})
+		case row.Username == "":
+			errs = append(errs, RowError{Line: row.Line, Err: fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)})
+		case utf8.RuneCountInString(row.Username) > maxUsernameLength:
+			errs = append(errs, RowError{Line: row.Line, Err: fmt.Errorf("%w: the user must be at most %d characters", ErrInvalidInput, maxUsernameLength)})
+		case seen[key] != 0:
+			errs = append(errs, RowError{Line: row.Line, Err: fmt.Errorf("%w: %s is already on line %d", ErrInvalidInput, row.Username, seen[key])})
+		default:
+			seen[key] = row.Line
+			valid = append(valid, row)
+		}
+	}
+	return valid, errs
+}
+
+// ExportScores calls fn with every score in guildID's tableName, or in every
+// guild for AllGuilds, ordered by guild and then username. Rows are passed on
+// as they are read so large tables are never held in memory.
+func (p *Postgres) ExportScores(tableName string, guildID string, fn func(ScoreRow) error, ctx context.Context) error {
+	game, err := gameID(ctx, p.pool, tableName)
+	if err != nil {
+		return err
+	}
+
+	var rows pgx.Rows
+	if guildID == AllGuilds {
+		rows, err = p.pool.Query(ctx, `SELECT guild_id, username, score FROM scores WHERE game_id = $1 ORDER BY guild_id, username`, game)
+	} else {
+		rows, err = p.pool.Query(ctx, `SELECT guild_id, username, score FROM scores WHERE game_id = $1 AND guild_id = $2 ORDER BY username`, game, guildID)
+	}
+	if err != nil {
+		return fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row ScoreRow
+		if err := rows.Scan(&row.GuildID, &row.Username, &row.Score); err != nil {
+			return fmt.Errorf("error scanning row: %w", err)
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return nil
+}
+
+// ImportScores sets the score of every user in rows, adding users who are
+// not in tableName yet. Rows must all be for guildID unless it is AllGuilds.
+// Rows that fail validation are reported in the result and skipped, and the
+// rest are loaded with COPY in a single transaction.
+func (p *Postgres) ImportScores(tableName string, guildID string, rows []ScoreRow, ctx context.Context) (ImportResult, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return ImportResult{}, err
+	}
+	valid, errs := validateScoreRows(rows, guildID)
+	result := ImportResult{Errors: errs}
+	if len(valid) == 0 {
+		return result, nil
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error importing the scores: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	game, err := gameID(ctx, tx, tableName)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		CREATE TEMPORARY TABLE score_import (
+			guild_id TEXT NOT NULL,
+			username TEXT NOT NULL,
+			score INTEGER NOT NULL
+		) ON COMMIT DROP`)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error importing the scores: %w", wrapPgError(err))
+	}
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"score_import"}, []string{"guild_id", "username", "score"},
+		pgx.CopyFromSlice(len(valid), func(i int) ([]any, error) {
+			return []any{valid[i].GuildID, valid[i].Username, valid[i].Score}, nil
+		}))
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error copying the scores: %w", wrapPgError(err))
+	}
+
+	// The changes are logged before the scores are overwritten, while the
+	// old ones can still be read.
+	_, err = tx.Exec(ctx, `
+		INSERT INTO score_events (game_id, table_name, guild_id, username, delta, score, reason)
+		SELECT $3, $1, i.guild_id, i.username, i.score - COALESCE(s.score, 0), i.score, $2
+		FROM score_import i
+		LEFT JOIN scores s ON s.game_id = $3 AND s.guild_id = i.guild_id AND s.username = i.username
+		WHERE i.score <> COALESCE(s.score, 0)`,
+		tableName, ReasonImport, game)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error recording the score changes: %w", wrapPgError(err))
+	}
+
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO scores (game_id, guild_id, username, score)
+		SELECT $1, guild_id, username, score FROM score_import
+		ON CONFLICT (game_id, guild_id, username) DO UPDATE SET score = EXCLUDED.score`,
+		game)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error importing the scores: %w", wrapPgError(err))
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("there was an error importing the scores: %w", wrapPgError(err))
+	}
+	result.Imported = int(tag.RowsAffected())
+	return result, nil
+}
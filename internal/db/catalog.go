@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Pokemon is a single entry in the Pokémon catalog. Legendary covers
+// mythical Pokémon too, and a BaseExperience of 0 means it is not known.
+type Pokemon struct {
+	Number         int      `json:"number"`
+	Name           string   `json:"name"`
+	Types          []string `json:"types"`
+	Generation     int      `json:"generation"`
+	Legendary      bool     `json:"legendary"`
+	BaseExperience int      `json:"base_experience,omitempty"`
+}
+
+func (p *Postgres) LoadPokemon(ctx context.Context) ([]Pokemon, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT number, name, types, generation, legendary, base_experience
+		FROM pokemon_catalog
+		ORDER BY number`)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error loading the pokemon catalog: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	var pokemon []Pokemon
+	for rows.Next() {
+		var pm Pokemon
+		err := rows.Scan(&pm.Number, &pm.Name, &pm.Types, &pm.Generation, &pm.Legendary, &pm.BaseExperience)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		pokemon = append(pokemon, pm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return pokemon, nil
+}
+
+// SavePokemon upserts every entry into the catalog table in one batch.
+func (p *Postgres) SavePokemon(ctx context.Context, pokemon []Pokemon) error {
+	batch := &pgx.Batch{}
+	for _, pm := range pokemon {
+		types := pm.Types
+		if types == nil {
+			types = []string{}
+		}
+		batch.Queue(`
+			INSERT INTO pokemon_catalog (number, name, types, generation, legendary, base_experience)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (number) DO UPDATE SET
+				name = EXCLUDED.name,
+				types = EXCLUDED.types,
+				generation = EXCLUDED.generation,
+				legendary = EXCLUDED.legendary,
+				base_experience = EXCLUDED.base_experience`,
+			pm.Number, pm.Name, types, pm.Generation, pm.Legendary, pm.BaseExperience)
+	}
+
+	err := p.pool.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return fmt.Errorf("there was an error saving the pokemon catalog: %w", wrapPgError(err))
+	}
+	return nil
+}
@@ -2,12 +2,13 @@ package db
 
 import (
 	"context"
-
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Config struct {
@@ -16,6 +17,11 @@ type Config struct {
 	User     string
 	Password string
 	DB       string
+
+	MaxConns          int32
+	MinConns          int32
+	HealthCheckPeriod time.Duration
+	MaxConnIdleTime   time.Duration
 }
 
 func LoadConfig() Config {
@@ -28,451 +34,263 @@ func LoadConfig() Config {
 	}
 }
 
-func (c *Config) TestDBConnection(ctx context.Context) (*pgx.Conn, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", c.User, c.Password, c.Host, c.Port, c.DB)
+func (c *Config) connString() string {
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s", c.User, c.Password, c.Host, c.Port, c.DB)
+}
+
+// Postgres is the pooled store shared by every handler. It is built once at
+// startup and must be closed on shutdown.
+type Postgres struct {
+	pool *pgxpool.Pool
+}
 
-	conn, err := pgx.Connect(ctx, connStr)
+func NewPostgres(ctx context.Context, c Config) (*Postgres, error) {
+	poolConfig, err := pgxpool.ParseConfig(c.connString())
 	if err != nil {
-		log.Fatal("Error opening connection to the database:", err)
+		return nil, fmt.Errorf("there was an error parsing the database config: %w", err)
+	}
+	if c.MaxConns > 0 {
+		poolConfig.MaxConns = c.MaxConns
+	}
+	if c.MinConns > 0 {
+		poolConfig.MinConns = c.MinConns
+	}
+	if c.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = c.HealthCheckPeriod
+	}
+	if c.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = c.MaxConnIdleTime
 	}
-	defer func(conn *pgx.Conn) {
-		err := conn.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(conn)
 
-	err = conn.Ping(ctx)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		log.Fatal("Error connecting to the database:", err)
-	} else {
-		fmt.Println("Successfully connected to the database!")
+		return nil, fmt.Errorf("there was an error creating the connection pool: %w", err)
 	}
-	return conn, err
+	return &Postgres{pool: pool}, nil
 }
 
-func (c *Config) Connect(ctx context.Context) (*pgx.Conn, error) {
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", c.User, c.Password, c.Host, c.Port, c.DB)
-	conn, err := pgx.Connect(ctx, connStr)
-	if conn == nil {
-		return nil, fmt.Errorf("connection is nil")
-	}
+func (p *Postgres) Close() {
+	p.pool.Close()
+}
 
+func (p *Postgres) Ping(ctx context.Context) error {
+	err := p.pool.Ping(ctx)
 	if err != nil {
-		log.Fatal("Error opening connection to the database:", err)
+		return fmt.Errorf("there was an error connecting to the database: %w: %w", ErrUnavailable, err)
 	}
-	return conn, err
+	return nil
 }
 
-func ListTables(ctx context.Context) ([]string, error) {
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		log.Fatal("Error testing DB connection:", err)
-	}
+// ListTables lists the games, which were each a table of their own before
+// the schema was fixed.
+func (p *Postgres) ListTables(ctx context.Context) ([]string, error) {
 	var tableNames []string
-	sql := `SELECT table_name FROM information_schema.tables WHERE table_schema = 'public'`
-	rows, err := DB.Query(ctx, sql)
+	rows, err := p.pool.Query(ctx, `SELECT name FROM games ORDER BY name`)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query tables: %v", err)
+		return nil, fmt.Errorf("failed to query tables: %w", wrapPgError(err))
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
+	defer rows.Close()
 
 	for rows.Next() {
 		var tableName string
 		err := rows.Scan(&tableName)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan row: %v", err)
+			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 		tableNames = append(tableNames, tableName)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating over rows: %v", err)
+		return nil, fmt.Errorf("error iterating over rows: %w", wrapPgError(err))
 	}
 
 	if tableNames == nil {
-		return nil, fmt.Errorf("there are no tables in the database")
+		return nil, fmt.Errorf("there are no tables in the database: %w", ErrNotFound)
 	}
 	return tableNames, nil
 }
 
-func CreateTable(tableName string, ctx context.Context) (string, error) {
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		log.Fatal("Error testing DB connection:", err)
-	}
-
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	if tableName == "" {
-		return "", fmt.Errorf("the table name must not be empty")
-	}
-
-	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (id SERIAL PRIMARY KEY, name TEXT);`, tableName)
-	_, err = DB.Exec(ctx, sql)
+// CreateTable adds a game called tableName. It is not an error if the game
+// is already there.
+func (p *Postgres) CreateTable(tableName string, ctx context.Context) (string, error) {
+	_, err := ensureGame(ctx, p.pool, tableName)
 	if err != nil {
-		return "", fmt.Errorf(`there was an error creating the table:, %s`, err)
+		return "", err
 	}
 
 	return fmt.Sprintf(`%s succesfully created.`, tableName), nil
 }
 
-func checkIfTableExists(tableName string) error {
-	if tableName == "" {
-		return fmt.Errorf("the table: %s must not be empty", tableName)
-	}
-	config := LoadConfig()
-	DB, err := config.Connect(context.Background())
-	if err != nil {
-		return err
-	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(context.Background())
-		if err != nil {
-			return
-		}
-	}(DB)
-	sql := `SELECT EXISTS (SELECT 1 FROM pg_tables WHERE schemaname = 'public' AND tablename = $1);`
-	var exists bool
-	err = DB.QueryRow(context.Background(), sql, tableName).Scan(&exists)
-	if err != nil {
-		return fmt.Errorf("there was an error checking if the table exists: %s", err)
-	}
-	if exists {
-		return nil
-	} else {
-		_, err := CreateTable(tableName, context.Background())
-		if err != nil {
-			return fmt.Errorf("there was an error creating your table: %s", err)
-		}
+// DeleteTable archives a game. Its scores, answers, rounds and score
+// history are moved into the archive schema rather than dropped, and the
+// name is free to be used again.
+func (p *Postgres) DeleteTable(tableName string, ctx context.Context) (string, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return "", err
 	}
-	return nil
-}
-
-func DeleteTable(tableName string, ctx context.Context) (string, error) {
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
+	tx, err := p.pool.Begin(ctx)
 	if err != nil {
-		log.Fatal("Error testing DB connection:", err)
+		return "", fmt.Errorf(`there was an error archiving the table: %w`, wrapPgError(err))
 	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
 
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	if tableName == "" {
-		return "", fmt.Errorf("the table name must not be empty")
-	}
-	sql := fmt.Sprintf(`DROP TABLE %s`, tableName)
-	_, err = DB.Exec(ctx, sql)
+	game, err := gameID(ctx, tx, tableName)
 	if err != nil {
-		return "", fmt.Errorf(`there was an error creating the table:, %s`, err)
+		return "", err
 	}
 
-	return fmt.Sprintf(`%s succesfully deleted.`, tableName), nil
-}
-
-func AddColumnsIfNotExists(tableName string, ctx context.Context) error {
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		return fmt.Errorf(`error testing DB connection: %s`, err)
+	// The archive tables have the same columns in the same order as the live
+	// ones, so whole rows can be copied across.
+	archive := []struct {
+		sql  string
+		args []any
+	}{
+		{`INSERT INTO archive.games SELECT *, now() FROM games WHERE id = $1`, []any{game}},
+		{`INSERT INTO archive.scores SELECT * FROM scores WHERE game_id = $1`, []any{game}},
+		{`INSERT INTO archive.answers SELECT * FROM answers WHERE game_id = $1`, []any{game}},
+		{`INSERT INTO archive.rounds SELECT * FROM rounds WHERE game_id = $1`, []any{game}},
+		{`INSERT INTO archive.score_events SELECT * FROM score_events WHERE game_id = $1`, []any{game}},
+		// Scores, answers, rounds and score events go with the game.
+		{`DELETE FROM games WHERE id = $1`, []any{game}},
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(context.Background())
+	for _, stmt := range archive {
+		_, err = tx.Exec(ctx, stmt.sql, stmt.args...)
 		if err != nil {
-			return
+			return "", fmt.Errorf(`there was an error archiving the table: %w`, wrapPgError(err))
 		}
-	}(DB)
-
-	sql_username := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "username" VARCHAR(255);`, tableName)
-	sql_score := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "score" INTEGER;`, tableName)
-
-	_, err = DB.Exec(ctx, sql_username)
-	if err != nil {
-		return fmt.Errorf("error adding username columns: %s", err)
 	}
 
-	_, err = DB.Exec(ctx, sql_score)
+	err = tx.Commit(ctx)
 	if err != nil {
-		return fmt.Errorf("error adding score columns: %s", err)
+		return "", fmt.Errorf(`there was an error archiving the table: %w`, wrapPgError(err))
 	}
 
-	return nil
+	return fmt.Sprintf(`%s succesfully archived.`, tableName), nil
 }
 
-func addColumnIfNotExistsAnswerTable(tableName string, column string, secondColumn string) error {
-	if tableName == "" || column == "" || secondColumn == "" {
-		return fmt.Errorf("tablename: %s, column: %s, or secondColumn: %s cannot be empty", tableName, column, secondColumn)
-	}
-
-	config := LoadConfig()
-	DB, err := config.Connect(context.Background())
-	if err != nil {
-		return fmt.Errorf(`error testing DB connection: %s`, err)
-	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(context.Background())
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	sql := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "%s" VARCHAR(255);`, tableName, column)
-	sqlSecond := fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS "%s" INTEGER`, tableName, secondColumn)
-
-	_, err = DB.Exec(context.Background(), sql)
-	if err != nil {
-		fmt.Printf("error adding %s columns: %s", column, err)
-		return fmt.Errorf("error adding %s columns: %s", column, err)
-	}
-	_, err = DB.Exec(context.Background(), sqlSecond)
-	if err != nil {
-		fmt.Printf("error adding %s columns: %s", secondColumn, err)
-		return fmt.Errorf("error adding %s columns: %w", secondColumn, err)
+func (p *Postgres) AddUserIfNotExist(tableName string, guildID string, username string, ctx context.Context) (string, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return "", err
 	}
-
-	return nil
-}
-
-func AddUserIfNotExist(tableName string, username string, ctx context.Context) (string, error) {
-	if tableName == "" || username == "" {
-		return "", fmt.Errorf("tablename: %s, and username: %s, cannot be empty", tableName, username)
+	if username == "" {
+		return "", fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
 	}
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
+	game, err := gameID(ctx, p.pool, tableName)
 	if err != nil {
-		log.Fatal("Error testing DB connection: ", err)
+		return "", err
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
 
-	sql := fmt.Sprintf(`SELECT COUNT(*) FROM %s WHERE "username" = $1;`, tableName)
 	var exists int
-	err = DB.QueryRow(ctx, sql, username).Scan(&exists)
+	err = p.pool.QueryRow(ctx, `SELECT COUNT(*) FROM scores WHERE game_id = $1 AND guild_id = $2 AND username = $3`, game, guildID, username).Scan(&exists)
 	if err != nil {
-		return "", fmt.Errorf("there was an error querying the database, %s", err)
+		return "", fmt.Errorf("there was an error querying the database: %w", wrapPgError(err))
 	}
 
 	if exists > 0 {
 		return "the user exists", nil
 	} else {
-		response, err := UpdateTableWithUser(tableName, username, ctx)
+		response, err := p.UpdateTableWithUser(tableName, guildID, username, ctx)
 		if err != nil {
-			return "", fmt.Errorf("there was an error creating the user in the database, %s", err)
+			return "", fmt.Errorf("there was an error creating the user in the database: %w", err)
 		}
 		return response, nil
 	}
 
 }
 
-func UpdateTableWithUser(tableName string, username string, ctx context.Context) (string, error) {
-	err := AddColumnsIfNotExists(tableName, ctx)
-	if err != nil {
-		return "", fmt.Errorf("error ensuring columns: %v", err)
+func (p *Postgres) UpdateTableWithUser(tableName string, guildID string, username string, ctx context.Context) (string, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return "", err
 	}
-
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
+	if username == "" {
+		return "", fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	game, err := gameID(ctx, p.pool, tableName)
 	if err != nil {
-		log.Fatal("Error testing DB connection: ", err)
+		return "", err
 	}
 
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	if tableName == "" {
-		return "", fmt.Errorf("the table name must not be empty")
-	}
-	if username == "" {
-		return "", fmt.Errorf("the user must not be empty")
-	}
-	sql := fmt.Sprintf(`INSERT INTO %s ("username", "score") VALUES ('%s', 0)`, tableName, username)
-	_, err = DB.Exec(ctx, sql)
+	_, err = p.pool.Exec(ctx, `INSERT INTO scores (game_id, guild_id, username, score) VALUES ($1, $2, $3, 0)`, game, guildID, username)
 	if err != nil {
-		return "", fmt.Errorf(`there was an error updating the table: %s`, err)
+		return "", fmt.Errorf(`there was an error updating the table: %w`, wrapPgError(err))
 	}
 
 	return fmt.Sprintf("The table %s was updated", tableName), nil
 }
 
-func GetCurrentScore(tableName string, username string, ctx context.Context) (int, error) {
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		log.Fatal("Error testing DB connection: ", err)
+func (p *Postgres) GetCurrentScore(tableName string, guildID string, username string, ctx context.Context) (int, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return 0, err
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-	if tableName == "" || username == "" {
-		return 0, fmt.Errorf("table or username must not be empty. table: %s, username: %s", tableName, username)
+	if username == "" {
+		return 0, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	game, err := gameID(ctx, p.pool, tableName)
+	if err != nil {
+		return 0, err
 	}
-	sql := fmt.Sprintf(`SELECT "score" FROM "%s" WHERE "username" = $1;`, tableName)
 	var score int
-	err = DB.QueryRow(ctx, sql, username).Scan(&score)
+	err = p.pool.QueryRow(ctx, `SELECT score FROM scores WHERE game_id = $1 AND guild_id = $2 AND username = $3`, game, guildID, username).Scan(&score)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			_, err := AddUserIfNotExist(tableName, username, ctx)
+		if errors.Is(err, pgx.ErrNoRows) {
+			_, err := p.AddUserIfNotExist(tableName, guildID, username, ctx)
 			if err != nil {
-				return 0, fmt.Errorf("there was an error creating your user, %s", err)
+				return 0, fmt.Errorf("there was an error creating your user: %w", err)
 			}
 			return 0, nil
 		}
-		return 0, fmt.Errorf("there was an error finding the score for a username. %s", err)
+		return 0, fmt.Errorf("there was an error finding the score for a username: %w", wrapPgError(err))
 	}
 	return score, nil
 }
 
-func UpdateScoreForUser(tableName string, username string, score int, column string, ctx context.Context) (string, error) {
-	if tableName == "" || username == "" || score == 0 || column == "" {
-		return "", fmt.Errorf("tablename: %s, username: %s, score: %d, or column: %s must not be empty", tableName, username, score, column)
+// PutAnswerInDB sets the answer a guild is playing for in a game, adding the
+// game if it is new.
+func (p *Postgres) PutAnswerInDB(tablenName string, guildID string, answer string, numberInArray int, ctx context.Context) (string, error) {
+	if _, err := quoteIdentifier(tablenName); err != nil {
+		return "", err
 	}
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		log.Fatal("Error testing DB connection: ", err)
+	if answer == "" {
+		return "", fmt.Errorf("%w: the answer cannot be empty", ErrInvalidInput)
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-	sql := fmt.Sprintf(`UPDATE %s SET "%s" = %d WHERE "username" = '%s'`, tableName, column, score, username)
-	_, err = DB.Exec(ctx, sql)
+	game, err := ensureGame(ctx, p.pool, tablenName)
 	if err != nil {
-		return "", fmt.Errorf("there was an error updating the users score. %s", err)
-	}
-
-	return "the score for the user has been updated", nil
-}
-
-func PutAnswerInDB(tablenName string, answer string, column string, secondColumn string, numberInArray int) (string, error) {
-	if tablenName == "" || answer == "" || column == "" || secondColumn == "" {
-		return "", fmt.Errorf("the tablename: %s, answer: %s, column: %s, numberInArray: %d, or secondColumn: %s cannot be empty", tablenName, answer, column, numberInArray, secondColumn)
+		return "", err
 	}
 
-	err := checkIfTableExists(tablenName)
-	if err != nil {
-		return "", fmt.Errorf("there was an error creating your table: %s", err)
-	}
-	err = addColumnIfNotExistsAnswerTable(tablenName, column, secondColumn)
+	_, err = p.pool.Exec(ctx, `
+		INSERT INTO answers (game_id, guild_id, answer, position) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (game_id, guild_id) DO UPDATE SET answer = EXCLUDED.answer, position = EXCLUDED.position, updated_at = now()`,
+		game, guildID, answer, numberInArray)
 	if err != nil {
-		return "", fmt.Errorf("there was an error adding your column: %s", column)
-	}
-
-	config := LoadConfig()
-	DB, err := config.Connect(context.Background())
-	if err != nil {
-		return "", fmt.Errorf("there was an error connecting to the database: %s", err)
-	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(context.Background())
-		if err != nil {
-			return
-		}
-	}(DB)
-	sql := fmt.Sprintf(`INSERT INTO %s ("id", "ANSWER", "POSITION") VALUES (1, $1, $2) ON CONFLICT ("id") DO UPDATE SET "ANSWER" = $1, "POSITION" = $2;`, tablenName)
-	_, err = DB.Exec(context.Background(), sql, answer, numberInArray)
-	if err != nil {
-		return "", fmt.Errorf("there was an error updating/creating the row: %s", err)
+		return "", fmt.Errorf("there was an error updating/creating the row: %w", wrapPgError(err))
 	}
 	return fmt.Sprintf("the %s table has been updated with %s", tablenName, answer), nil
 }
 
-func ReadAnswerFromDB(tableName string, column string, ctx context.Context) (string, error) {
-	if tableName == "" || column == "" {
-		return "", fmt.Errorf("tablename: %s or column: %s cannot be empty", tableName, column)
+// ReadAnswerFromDB reads a guild's answer in a game. column is ANSWER or
+// POSITION, as the answer tables had.
+func (p *Postgres) ReadAnswerFromDB(tableName string, guildID string, column string, ctx context.Context) (string, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return "", err
 	}
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
+	col, err := answerColumn(column)
 	if err != nil {
-		return "", fmt.Errorf("there was an error connecting to the database: %s", err)
+		return "", err
 	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	sql := fmt.Sprintf("SELECT '%s' FROM %s WHERE id = 1", column, tableName)
-	var answer string
-	err = DB.QueryRow(ctx, sql).Scan(&answer)
+	game, err := gameID(ctx, p.pool, tableName)
 	if err != nil {
-		return "", fmt.Errorf("there was an error finding the answer: %s", err)
+		return "", err
 	}
-	return answer, nil
-}
 
-func GetLeaderboard(tableName string, ctx context.Context) (string, error) {
-	if tableName == "" {
-		return "", fmt.Errorf("tablename: %s", tableName)
-	}
-	config := LoadConfig()
-	DB, err := config.Connect(ctx)
-	if err != nil {
-		return "", fmt.Errorf("there was an error connecting to the database: %s", err)
-	}
-	defer func(DB *pgx.Conn) {
-		err := DB.Close(ctx)
-		if err != nil {
-			return
-		}
-	}(DB)
-
-	sql := fmt.Sprintf(`SELECT "username", "score" FROM %s ORDER BY "score" DESC LIMIT 10;`, tableName)
-	rows, err := DB.Query(ctx, sql)
+	// answerColumn only ever returns one of the answers table's own columns.
+	sql := fmt.Sprintf(`SELECT %s::text FROM answers WHERE game_id = $1 AND guild_id = $2`, col)
+	var answer string
+	err = p.pool.QueryRow(ctx, sql, game, guildID).Scan(&answer)
 	if err != nil {
-		return "", fmt.Errorf("error executing query: %s", err)
+		return "", fmt.Errorf("there was an error finding the answer: %w", wrapPgError(err))
 	}
-	defer rows.Close()
-
-	var leaderboard string
-	for rows.Next() {
-		var username string
-		var score int
-		err := rows.Scan(&username, &score)
-		if err != nil {
-			return "", fmt.Errorf("error scanning row: %s", err)
-		}
-		leaderboard += fmt.Sprintf("Username: %s, Score: %d\n", username, score)
-	}
-
-	if err = rows.Err(); err != nil {
-		return "", fmt.Errorf("error iterating through rows: %s", err)
-	}
-
-	if leaderboard == "" {
-		return "No leaderboard data found.", nil
-	}
-
-	return leaderboard, nil
+	return answer, nil
 }
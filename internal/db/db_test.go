@@ -0,0 +1,436 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+var hostileIdentifiers = []string{
+	"",
+	"pokemon_scores; DROP TABLE pokemon_scores; --",
+	`pokemon_scores" WHERE 1=1 --`,
+	"pokemon_scores'",
+	"pokemon scores",
+	"pokemon_scores--",
+	"1pokemon_scores",
+	"public.pokemon_scores",
+	"x'||pg_sleep(5)||'",
+	strings.Repeat("a", 64),
+}
+
+// TestHostileIdentifiers feeds hostile table and column names through every
+// exported function that takes one. They must all be rejected before any SQL
+// is sent, so this runs against a store with no pool behind it.
+func TestHostileIdentifiers(t *testing.T) {
+	ctx := context.Background()
+	p := &Postgres{}
+
+	calls := map[string]func(name string) error{
+		"CreateTable": func(name string) error {
+			_, err := p.CreateTable(name, ctx)
+			return err
+		},
+		"DeleteTable": func(name string) error {
+			_, err := p.DeleteTable(name, ctx)
+			return err
+		},
+		"DescribeTable": func(name string) error {
+			_, err := p.DescribeTable(name, ctx)
+			return err
+		},
+		"AddUserIfNotExist": func(name string) error {
+			_, err := p.AddUserIfNotExist(name, "test-guild", "test-user", ctx)
+			return err
+		},
+		"UpdateTableWithUser": func(name string) error {
+			_, err := p.UpdateTableWithUser(name, "test-guild", "test-user", ctx)
+			return err
+		},
+		"GetCurrentScore": func(name string) error {
+			_, err := p.GetCurrentScore(name, "test-guild", "test-user", ctx)
+			return err
+		},
+		"ChangeScore": func(name string) error {
+			_, err := p.ChangeScore(name, "test-guild", "test-user", ScoreChange{Op: ScoreIncrement, Amount: 1}, ctx)
+			return err
+		},
+		"IdentifyUser": func(name string) error {
+			_, err := p.IdentifyUser(name, "test-guild", "1234", "test-user", ctx)
+			return err
+		},
+		"MergeUsers": func(name string) error {
+			_, err := p.MergeUsers(name, "test-guild", "test-user", "test-user-2", ctx)
+			return err
+		},
+		"ExportScores": func(name string) error {
+			return p.ExportScores(name, "test-guild", func(ScoreRow) error { return nil }, ctx)
+		},
+		"ImportScores": func(name string) error {
+			_, err := p.ImportScores(name, "test-guild", []ScoreRow{{Line: 2, GuildID: "test-guild", Username: "test-user", Score: 1}}, ctx)
+			return err
+		},
+		"ScoreHistory": func(name string) error {
+			_, err := p.ScoreHistory(name, "test-guild", "test-user", Page{}, ctx)
+			return err
+		},
+		"PutAnswerInDB": func(name string) error {
+			_, err := p.PutAnswerInDB(name, "test-guild", "pikachu", 1, ctx)
+			return err
+		},
+		"ReadAnswerFromDB table": func(name string) error {
+			_, err := p.ReadAnswerFromDB(name, "test-guild", "ANSWER", ctx)
+			return err
+		},
+		"ReadAnswerFromDB column": func(name string) error {
+			_, err := p.ReadAnswerFromDB("pokemon_answers", "test-guild", name, ctx)
+			return err
+		},
+		"GetLeaderboard": func(name string) error {
+			_, err := p.GetLeaderboard(name, "test-guild", ctx)
+			return err
+		},
+		"Standing": func(name string) error {
+			_, err := p.Standing(name, "test-guild", "test-user", 2, ctx)
+			return err
+		},
+		"Leaderboard": func(name string) error {
+			_, err := p.Leaderboard(name, "test-guild", LeaderboardPage{}, ctx)
+			return err
+		},
+	}
+
+	for fn, call := range calls {
+		for _, name := range hostileIdentifiers {
+			t.Run(fn+"/"+name, func(t *testing.T) {
+				err := call(name)
+				assert.Check(t, errors.Is(err, ErrInvalidIdentifier), "got: %v", err)
+			})
+		}
+	}
+}
+
+// TestHostileUsernames checks usernames are bound as values rather than
+// spliced into SQL, so they round trip unchanged and do no damage.
+func TestHostileUsernames(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, LoadConfig())
+	assert.NilError(t, err)
+	t.Cleanup(p.Close)
+
+	const table = "hostile_usernames"
+	_, err = p.CreateTable(table, ctx)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		_, _ = p.DeleteTable(table, context.Background())
+	})
+
+	usernames := []string{
+		"Robert'); DROP TABLE hostile_usernames; --",
+		`test-user" OR "1"="1`,
+		"test-user' OR '1'='1",
+		"$1",
+		"\\'; SELECT pg_sleep(5); --",
+	}
+	for _, username := range usernames {
+		t.Run(username, func(t *testing.T) {
+			_, err := p.UpdateTableWithUser(table, "", username, ctx)
+			assert.NilError(t, err)
+
+			_, err = p.ChangeScore(table, "", username, ScoreChange{Op: ScoreSet, Amount: 7}, ctx)
+			assert.NilError(t, err)
+
+			score, err := p.GetCurrentScore(table, "", username, ctx)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(score, 7))
+
+			leaderboard, err := p.GetLeaderboard(table, "", ctx)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Contains(leaderboard, username))
+		})
+	}
+
+	tables, err := p.ListTables(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Contains(tables, table))
+}
+
+func TestReadAnswerFromDB_ReturnsValue(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, LoadConfig())
+	assert.NilError(t, err)
+	t.Cleanup(p.Close)
+
+	const table = "hostile_answers"
+	t.Cleanup(func() {
+		_, _ = p.DeleteTable(table, context.Background())
+	})
+
+	_, err = p.PutAnswerInDB(table, "", "mr-mime", 122, ctx)
+	assert.NilError(t, err)
+
+	answer, err := p.ReadAnswerFromDB(table, "", "ANSWER", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(answer, "mr-mime"))
+}
+
+// TestGuildIsolation checks one guild's scores and answers are never seen or
+// changed by another.
+func TestGuildIsolation(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, LoadConfig())
+	assert.NilError(t, err)
+	t.Cleanup(p.Close)
+
+	const scores, answers = "guild_scores", "guild_answers"
+	_, err = p.CreateTable(scores, ctx)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		_, _ = p.DeleteTable(scores, context.Background())
+		_, _ = p.DeleteTable(answers, context.Background())
+	})
+
+	_, err = p.ChangeScore(scores, "guild-a", "ash", ScoreChange{Op: ScoreSet, Amount: 5}, ctx)
+	assert.NilError(t, err)
+	_, err = p.ChangeScore(scores, "guild-b", "ash", ScoreChange{Op: ScoreIncrement, Amount: 2}, ctx)
+	assert.NilError(t, err)
+	_, err = p.PutAnswerInDB(answers, "guild-a", "mew", 151, ctx)
+	assert.NilError(t, err)
+	_, err = p.PutAnswerInDB(answers, "guild-b", "ditto", 132, ctx)
+	assert.NilError(t, err)
+
+	score, err := p.GetCurrentScore(scores, "guild-a", "ash", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(score, 5))
+
+	leaderboard, err := p.Leaderboard(scores, "guild-b", LeaderboardPage{}, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(leaderboard.Entries, []LeaderboardEntry{{Rank: 1, Username: "ash", Score: 2}}))
+
+	history, err := p.ScoreHistory(scores, "guild-b", "ash", Page{}, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(history.Total, 1))
+
+	answer, err := p.ReadAnswerFromDB(answers, "guild-a", "ANSWER", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(answer, "mew"))
+
+	_, err = p.ReadAnswerFromDB(answers, "guild-c", "ANSWER", ctx)
+	assert.Check(t, errors.Is(err, ErrNotFound), "got: %v", err)
+}
+
+// TestScoreEventsFollowTheGame checks a table that is deleted and made again
+// starts without the old table's score history.
+func TestScoreEventsFollowTheGame(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, LoadConfig())
+	assert.NilError(t, err)
+	t.Cleanup(p.Close)
+
+	const table = "recreated_scores"
+	_, err = p.CreateTable(table, ctx)
+	assert.NilError(t, err)
+	t.Cleanup(func() {
+		_, _ = p.DeleteTable(table, context.Background())
+	})
+
+	_, err = p.ChangeScore(table, "", "ash", ScoreChange{Op: ScoreIncrement, Amount: 5}, ctx)
+	assert.NilError(t, err)
+	summary, err := p.DescribeTable(table, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(summary.Events, 1))
+
+	_, err = p.DeleteTable(table, ctx)
+	assert.NilError(t, err)
+	_, err = p.CreateTable(table, ctx)
+	assert.NilError(t, err)
+
+	history, err := p.ScoreHistory(table, "", "ash", Page{}, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(history.Total, 0))
+	summary, err = p.DescribeTable(table, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(summary.Events, 0))
+
+	_, err = p.ScoreHistory("missing_scores", "", "ash", Page{}, ctx)
+	assert.Check(t, errors.Is(err, ErrNotFound), "got: %v", err)
+}
+
+// TestMigrateLegacyAnswers checks an answer table from before the fixed
+// schema, which every earlier migration gave username and score columns, has
+// its answer moved into answers rather than being dropped with the score
+// tables.
+func TestMigrateLegacyAnswers(t *testing.T) {
+	ctx := context.Background()
+	p, err := NewPostgres(ctx, LoadConfig())
+	assert.NilError(t, err)
+	t.Cleanup(p.Close)
+
+	_, err = p.MigrateUp(ctx)
+	assert.NilError(t, err)
+	status, err := p.MigrationStatus(ctx)
+	assert.NilError(t, err)
+	steps := 0
+	for _, s := range status {
+		if s.Version >= 11 && s.AppliedAt != nil {
+			steps++
+		}
+	}
+	_, err = p.MigrateDown(ctx, steps)
+	assert.NilError(t, err)
+
+	const table = "legacy_answers"
+	t.Cleanup(func() {
+		_, _ = p.MigrateUp(context.Background())
+		_, _ = p.DeleteTable(table, context.Background())
+	})
+	_, err = p.pool.Exec(ctx, `
+		CREATE TABLE legacy_answers (
+			id SERIAL PRIMARY KEY,
+			name TEXT,
+			"username" VARCHAR(255),
+			"score" INTEGER,
+			"guild_id" TEXT NOT NULL DEFAULT '' UNIQUE,
+			"user_id" BIGINT,
+			"ANSWER" VARCHAR(255),
+			"POSITION" INTEGER
+		)`)
+	assert.NilError(t, err)
+	_, err = p.pool.Exec(ctx, `INSERT INTO legacy_answers ("ANSWER", "POSITION") VALUES ('mr-mime', 122)`)
+	assert.NilError(t, err)
+
+	_, err = p.MigrateUp(ctx)
+	assert.NilError(t, err)
+
+	answer, err := p.ReadAnswerFromDB(table, "", "ANSWER", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(answer, "mr-mime"))
+	position, err := p.ReadAnswerFromDB(table, "", "POSITION", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(position, "122"))
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected error
+	}{
+		{code: pgUniqueViolation, expected: ErrConflict},
+		{code: pgUndefinedTable, expected: ErrNotFound},
+		{code: pgInvalidName, expected: ErrInvalidIdentifier},
+		{code: pgNameTooLong, expected: ErrInvalidIdentifier},
+		// A syntax error is a bug in our own SQL, not the caller's input.
+		{code: "42601", expected: nil},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.code, func(t *testing.T) {
+			assert.Check(t, errorKind(&pgconn.PgError{Code: tt.code}) == tt.expected)
+		})
+	}
+}
+
+func TestAnswerColumn(t *testing.T) {
+	tests := []struct {
+		column   string
+		expected string
+		err      error
+	}{
+		{column: "ANSWER", expected: "answer"},
+		{column: "answer", expected: "answer"},
+		{column: "POSITION", expected: "position"},
+		{column: "name", err: ErrNotFound},
+		{column: "ANSWER; --", err: ErrInvalidIdentifier},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.column, func(t *testing.T) {
+			column, err := answerColumn(tt.column)
+			if tt.err != nil {
+				assert.Check(t, errors.Is(err, tt.err), "got: %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(column, tt.expected))
+		})
+	}
+}
+
+func TestPeriodSince(t *testing.T) {
+	// A Wednesday afternoon, in a zone behind UTC so the UTC date differs.
+	now := time.Date(2025, 1, 15, 20, 30, 0, 0, time.FixedZone("UTC-5", -5*60*60))
+	tests := []struct {
+		period   Period
+		expected time.Time
+	}{
+		{period: PeriodDaily, expected: time.Date(2025, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodWeekly, expected: time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodMonthly, expected: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{period: PeriodAllTime, expected: time.Time{}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(string(tt.period), func(t *testing.T) {
+			assert.Check(t, cmp.Equal(tt.period.Since(now), tt.expected))
+		})
+	}
+}
+
+func TestNewAPIKey(t *testing.T) {
+	prefix, key, err := newAPIKey()
+	assert.NilError(t, err)
+
+	got, ok := apiKeyPrefix(key)
+	assert.Check(t, ok)
+	assert.Check(t, cmp.Equal(got, prefix))
+
+	for _, key := range []string{"", "ak", "ak_" + prefix, "xx_" + prefix + "_secret", "ak_short_secret", key + "_extra"} {
+		_, ok := apiKeyPrefix(key)
+		assert.Check(t, !ok, key)
+	}
+}
+
+func TestNewStaticAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticAPIKeys(map[string]APIKey{
+		"bot-key":       {Client: "discord-bot", Scopes: []Scope{ScopeGamePlay, ScopeReadLeaderboard, ScopeWriteScore}},
+		"dashboard-key": {Client: "dashboard"},
+	})
+	assert.NilError(t, err)
+
+	key, err := keys.LookupAPIKey(ctx, "bot-key")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(key, APIKey{
+		Client: "discord-bot",
+		Prefix: staticAPIKeyPrefix,
+		Scopes: []Scope{ScopeGamePlay, ScopeReadLeaderboard, ScopeWriteScore},
+	}))
+
+	_, err = APIKeyStores{StaticAPIKeys{}, keys}.LookupAPIKey(ctx, "dashboard-key")
+	assert.NilError(t, err)
+
+	_, err = keys.LookupAPIKey(ctx, "discord-bot")
+	assert.Check(t, errors.Is(err, ErrNotFound), "got: %v", err)
+
+	_, err = NewStaticAPIKeys(map[string]APIKey{"bot-key": {}})
+	assert.Check(t, errors.Is(err, ErrInvalidInput), "got: %v", err)
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes([]string{"bot", "game:play", "admin:tables"})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(scopes, []Scope{ScopeAdminTables, ScopeGamePlay, ScopeReadLeaderboard}))
+
+	scopes, err = ParseScopes(nil)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(scopes, 0))
+
+	_, err = ParseScopes([]string{"write:scores"})
+	assert.Check(t, errors.Is(err, ErrInvalidInput), "got: %v", err)
+}
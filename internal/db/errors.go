@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// These are the error kinds the db package returns. Callers should use
+// errors.Is against them rather than inspecting Postgres errors directly.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+	ErrInvalidInput      = errors.New("invalid input")
+	ErrUnavailable       = errors.New("database unavailable")
+	ErrConflict          = errors.New("conflict")
+)
+
+// Postgres error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgUniqueViolation = "23505"
+	pgDuplicateTable  = "42P07"
+	pgUndefinedTable  = "42P01"
+	pgUndefinedColumn = "42703"
+	pgInvalidName     = "42602"
+	pgNameTooLong     = "42622"
+)
+
+// wrapPgError returns err wrapped with the matching error kind, or err
+// unchanged if it does not match any of them.
+func wrapPgError(err error) error {
+	kind := errorKind(err)
+	if kind == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
+
+func errorKind(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case pgUniqueViolation, pgDuplicateTable:
+			return ErrConflict
+		case pgUndefinedTable, pgUndefinedColumn:
+			return ErrNotFound
+		case pgInvalidName, pgNameTooLong:
+			// Postgres turned away a name it was given, such as one
+			// too long for it, rather than our SQL.
+			return ErrInvalidIdentifier
+		}
+		// Identifiers are checked before any SQL is sent, so anything
+		// else, such as a syntax error, is a bug in our SQL and is left
+		// as is.
+		return nil
+	}
+
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrUnavailable
+	}
+	return nil
+}
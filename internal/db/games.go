@@ -0,0 +1,110 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Scores and answers used to live in a table per game, made on demand by
+// CreateTable and PutAnswerInDB. They are now kept in the fixed games,
+// scores and answers tables, and the table names callers pass in are the
+// names of games. The functions here map those names and the old column
+// names onto the fixed schema.
+
+// querier is the part of a pool or transaction needed to look up a game.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// gameID returns the ID of the game called tableName. tableName is still
+// checked as an identifier so names stay usable as table names, and so a
+// hostile one is rejected before any SQL is sent.
+func gameID(ctx context.Context, q querier, tableName string) (int64, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := q.QueryRow(ctx, `SELECT id FROM games WHERE name = $1`, tableName).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("the table %s does not exist: %w", tableName, ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("there was an error finding the table: %w", wrapPgError(err))
+	}
+	return id, nil
+}
+
+// ensureGame returns the ID of the game called tableName, adding it if it
+// is new.
+func ensureGame(ctx context.Context, q querier, tableName string) (int64, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := q.QueryRow(ctx, `
+		INSERT INTO games (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`,
+		tableName,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error creating the table: %w", wrapPgError(err))
+	}
+	return id, nil
+}
+
+// answerColumn maps a column of the old answer tables onto the answers
+// table. The old columns were upper case, but any case is accepted.
+func answerColumn(column string) (string, error) {
+	if _, err := quoteIdentifier(column); err != nil {
+		return "", err
+	}
+	switch strings.ToLower(column) {
+	case "answer":
+		return "answer", nil
+	case "position":
+		return "position", nil
+	default:
+		return "", fmt.Errorf("the column %s does not exist: %w", column, ErrNotFound)
+	}
+}
+
+// TableSummary is what a game holds, for checking what creating or
+// archiving it would do before doing it.
+type TableSummary struct {
+	Name    string
+	Exists  bool
+	Scores  int
+	Answers int
+	Events  int
+}
+
+// DescribeTable summarises the game called tableName. A game that does not
+// exist is not an error, it is reported with Exists false.
+func (p *Postgres) DescribeTable(tableName string, ctx context.Context) (TableSummary, error) {
+	summary := TableSummary{Name: tableName}
+	game, err := gameID(ctx, p.pool, tableName)
+	if errors.Is(err, ErrNotFound) {
+		return summary, nil
+	}
+	if err != nil {
+		return TableSummary{}, err
+	}
+
+	summary.Exists = true
+	err = p.pool.QueryRow(ctx, `
+		SELECT
+			(SELECT count(*) FROM scores WHERE game_id = $1),
+			(SELECT count(*) FROM answers WHERE game_id = $1),
+			(SELECT count(*) FROM score_events WHERE game_id = $1)`,
+		game,
+	).Scan(&summary.Scores, &summary.Answers, &summary.Events)
+	if err != nil {
+		return TableSummary{}, fmt.Errorf("there was an error describing the table: %w", wrapPgError(err))
+	}
+	return summary, nil
+}
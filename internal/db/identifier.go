@@ -0,0 +1,22 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// identifierPattern only lets through plain Postgres identifiers no longer
+// than NAMEDATALEN-1 bytes.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// quoteIdentifier validates name as a table or column name and returns it
+// quoted so it can be placed into SQL text. Values must never go through
+// here, they are bound as query parameters instead.
+func quoteIdentifier(name string) (string, error) {
+	if !identifierPattern.MatchString(name) {
+		return "", fmt.Errorf("%w: %q is not a valid table or column name", ErrInvalidIdentifier, name)
+	}
+	return pgx.Identifier{name}.Sanitize(), nil
+}
@@ -0,0 +1,223 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// LeaderboardEntry is one player on a leaderboard. Players on the same score
+// share a Rank, and the next score down is ranked one lower, so ranks go
+// 1, 2, 2, 3.
+type LeaderboardEntry struct {
+	Rank     int
+	Username string
+	Score    int
+}
+
+// LeaderboardPage picks out part of a leaderboard. If Since is set only
+// points scored from then on count, otherwise it is the all-time leaderboard.
+type LeaderboardPage struct {
+	Page
+	Since time.Time
+}
+
+func (p LeaderboardPage) validate() (LeaderboardPage, error) {
+	var err error
+	p.Page, err = p.Page.validate()
+	return p, err
+}
+
+type Leaderboard struct {
+	Entries []LeaderboardEntry
+	// Total is how many players are on the whole leaderboard.
+	Total int
+	Page  LeaderboardPage
+}
+
+// NextOffset is where the next page starts, or 0 if this is the last page.
+func (l Leaderboard) NextOffset() int {
+	return l.Page.nextOffset(len(l.Entries), l.Total)
+}
+
+// String renders the leaderboard in the plain text format the Discord bot
+// has always shown.
+func (l Leaderboard) String() string {
+	if len(l.Entries) == 0 {
+		return "No leaderboard data found."
+	}
+	var b strings.Builder
+	for _, entry := range l.Entries {
+		fmt.Fprintf(&b, "Username: %s, Score: %d\n", entry.Username, entry.Score)
+	}
+	return b.String()
+}
+
+// GetLeaderboard returns the top ten players in a guild as text.
+func (p *Postgres) GetLeaderboard(tableName string, guildID string, ctx context.Context) (string, error) {
+	leaderboard, err := p.Leaderboard(tableName, guildID, LeaderboardPage{}, ctx)
+	if err != nil {
+		return "", err
+	}
+	return leaderboard.String(), nil
+}
+
+// Leaderboard returns one page of a guild's players in tableName, highest
+// score first. Players on the same score are ordered by username so pages
+// are stable.
+func (p *Postgres) Leaderboard(tableName string, guildID string, page LeaderboardPage, ctx context.Context) (Leaderboard, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return Leaderboard{}, err
+	}
+	page, err := page.validate()
+	if err != nil {
+		return Leaderboard{}, err
+	}
+	// Looking the game up first makes a typo a not found rather than an
+	// empty leaderboard.
+	game, err := gameID(ctx, p.pool, tableName)
+	if err != nil {
+		return Leaderboard{}, err
+	}
+	if !page.Since.IsZero() {
+		return p.periodLeaderboard(game, guildID, page, ctx)
+	}
+
+	leaderboard := Leaderboard{Page: page}
+	err = p.pool.QueryRow(ctx, `SELECT count(*) FROM scores WHERE game_id = $1 AND guild_id = $2`, game, guildID).Scan(&leaderboard.Total)
+	if err != nil {
+		return Leaderboard{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT DENSE_RANK() OVER (ORDER BY score DESC), username, score
+		FROM scores
+		WHERE game_id = $1 AND guild_id = $2
+		ORDER BY score DESC, username
+		LIMIT $3 OFFSET $4`,
+		game, guildID, page.Limit, page.Offset)
+	if err != nil {
+		return Leaderboard{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LeaderboardEntry
+		err := rows.Scan(&entry.Rank, &entry.Username, &entry.Score)
+		if err != nil {
+			return Leaderboard{}, fmt.Errorf("error scanning row: %w", err)
+		}
+		leaderboard.Entries = append(leaderboard.Entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Leaderboard{}, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return leaderboard, nil
+}
+
+// MaxStandingWindow caps how many players either side of a user a standing
+// can include.
+const MaxStandingWindow = 25
+
+// Standing is where one player sits on a leaderboard, with the players just
+// above and below them.
+type Standing struct {
+	LeaderboardEntry
+	// Percentile is the percentage of the other players with a lower score.
+	// A player alone on the leaderboard is in the 100th percentile.
+	Percentile float64
+	Total      int
+	// Above is ordered from the highest score down, ending with the player
+	// just above this one. Below starts with the player just below.
+	Above []LeaderboardEntry
+	Below []LeaderboardEntry
+}
+
+func validateStandingWindow(window int) error {
+	if window < 0 || window > MaxStandingWindow {
+		return fmt.Errorf("%w: window: %d must be between 0 and %d", ErrInvalidInput, window, MaxStandingWindow)
+	}
+	return nil
+}
+
+// Standing returns username's rank in a guild's tableName along with up to
+// window players either side of them.
+func (p *Postgres) Standing(tableName string, guildID string, username string, window int, ctx context.Context) (Standing, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return Standing{}, err
+	}
+	if username == "" {
+		return Standing{}, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	if err := validateStandingWindow(window); err != nil {
+		return Standing{}, err
+	}
+	game, err := gameID(ctx, p.pool, tableName)
+	if err != nil {
+		return Standing{}, err
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		WITH ranked AS (
+			SELECT username, score,
+				DENSE_RANK() OVER (ORDER BY score DESC) AS rank,
+				ROW_NUMBER() OVER (ORDER BY score DESC, username) AS position,
+				PERCENT_RANK() OVER (ORDER BY score) AS percent_rank,
+				COUNT(*) OVER () AS total
+			FROM scores
+			WHERE game_id = $1 AND guild_id = $2
+		), me AS (
+			SELECT position FROM ranked WHERE username = $3
+		)
+		SELECT ranked.rank, ranked.username, ranked.score, ranked.percent_rank, ranked.total
+		FROM ranked, me
+		WHERE ranked.position BETWEEN me.position - $4 AND me.position + $4
+		ORDER BY ranked.position`,
+		game, guildID, username, window)
+	if err != nil {
+		return Standing{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	var standing Standing
+	found := false
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var percentRank float64
+		var total int
+		err := rows.Scan(&entry.Rank, &entry.Username, &entry.Score, &percentRank, &total)
+		if err != nil {
+			return Standing{}, fmt.Errorf("error scanning row: %w", err)
+		}
+		switch {
+		case entry.Username == username:
+			found = true
+			standing.LeaderboardEntry = entry
+			standing.Percentile = percentile(percentRank, total)
+			standing.Total = total
+		case found:
+			standing.Below = append(standing.Below, entry)
+		default:
+			standing.Above = append(standing.Above, entry)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return Standing{}, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	if !found {
+		return Standing{}, fmt.Errorf("the user %s is not on the leaderboard: %w", username, ErrNotFound)
+	}
+	return standing, nil
+}
+
+// percentile turns a PERCENT_RANK over ascending scores into a percentile.
+func percentile(percentRank float64, total int) float64 {
+	if total <= 1 {
+		return 100
+	}
+	return math.Round(percentRank*1000) / 10
+}
@@ -0,0 +1,765 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Store. It follows the same validation and error
+// kinds as Postgres so handlers can be tested without a database. It starts
+// out with the tables the migrations create.
+type Memory struct {
+	mu      sync.Mutex
+	tables  map[string]*memoryTable
+	order   []string
+	rounds  []Round
+	pokemon map[int]Pokemon
+	events  []ScoreEvent
+	users   map[string]*User
+
+	// Now is when score changes are logged as happening, defaulting to
+	// time.Now.
+	Now func() time.Time
+}
+
+type memoryTable struct {
+	users map[member]int
+	order []member
+	// owners is the User.ID each identified row belongs to.
+	owners map[member]int64
+	// answers is keyed by guild and then column.
+	answers map[string]map[string]string
+}
+
+// member is a user in one guild.
+type member struct {
+	guildID  string
+	username string
+}
+
+func NewMemory() *Memory {
+	m := &Memory{tables: map[string]*memoryTable{}, pokemon: map[int]Pokemon{}, users: map[string]*User{}, Now: time.Now}
+	m.createTable(PokemonScoresTable)
+	return m
+}
+
+func (m *Memory) createTable(tableName string) *memoryTable {
+	t, ok := m.tables[tableName]
+	if !ok {
+		t = &memoryTable{users: map[member]int{}, owners: map[member]int64{}, answers: map[string]map[string]string{}}
+		m.tables[tableName] = t
+		m.order = append(m.order, tableName)
+	}
+	return t
+}
+
+func (m *Memory) table(tableName string) (*memoryTable, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return nil, err
+	}
+	t, ok := m.tables[tableName]
+	if !ok {
+		return nil, fmt.Errorf("the table %s does not exist: %w", tableName, ErrNotFound)
+	}
+	return t, nil
+}
+
+func (m *Memory) ListTables(_ context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.order) == 0 {
+		return nil, fmt.Errorf("there are no tables in the database: %w", ErrNotFound)
+	}
+	return append([]string(nil), m.order...), nil
+}
+
+func (m *Memory) CreateTable(tableName string, _ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return "", err
+	}
+	m.createTable(tableName)
+	return fmt.Sprintf(`%s succesfully created.`, tableName), nil
+}
+
+// DeleteTable drops the table outright, as there is no archive to keep it
+// in.
+func (m *Memory) DeleteTable(tableName string, _ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := m.table(tableName); err != nil {
+		return "", err
+	}
+	delete(m.tables, tableName)
+	events := m.events[:0]
+	for _, e := range m.events {
+		if e.TableName != tableName {
+			events = append(events, e)
+		}
+	}
+	m.events = events
+	for i, name := range m.order {
+		if name == tableName {
+			m.order = append(m.order[:i], m.order[i+1:]...)
+			break
+		}
+	}
+	return fmt.Sprintf(`%s succesfully archived.`, tableName), nil
+}
+
+func (m *Memory) DescribeTable(tableName string, _ context.Context) (TableSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	summary := TableSummary{Name: tableName}
+	t, err := m.table(tableName)
+	if errors.Is(err, ErrNotFound) {
+		return summary, nil
+	}
+	if err != nil {
+		return TableSummary{}, err
+	}
+	summary.Exists = true
+	summary.Scores = len(t.users)
+	summary.Answers = len(t.answers)
+	for _, e := range m.events {
+		if e.TableName == tableName {
+			summary.Events++
+		}
+	}
+	return summary, nil
+}
+
+func (m *Memory) AddUserIfNotExist(tableName string, guildID string, username string, ctx context.Context) (string, error) {
+	m.mu.Lock()
+	t, err := m.table(tableName)
+	if err != nil {
+		m.mu.Unlock()
+		return "", err
+	}
+	_, exists := t.users[member{guildID, username}]
+	m.mu.Unlock()
+
+	if exists {
+		return "the user exists", nil
+	}
+	return m.UpdateTableWithUser(tableName, guildID, username, ctx)
+}
+
+func (m *Memory) UpdateTableWithUser(tableName string, guildID string, username string, _ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return "", err
+	}
+	if username == "" {
+		return "", fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	key := member{guildID, username}
+	if _, ok := t.users[key]; ok {
+		return "", fmt.Errorf("the user %s already exists: %w", username, ErrConflict)
+	}
+	t.users[key] = 0
+	t.order = append(t.order, key)
+	return fmt.Sprintf("The table %s was updated", tableName), nil
+}
+
+func (m *Memory) GetCurrentScore(tableName string, guildID string, username string, ctx context.Context) (int, error) {
+	m.mu.Lock()
+	t, err := m.table(tableName)
+	if err != nil {
+		m.mu.Unlock()
+		return 0, err
+	}
+	if username == "" {
+		m.mu.Unlock()
+		return 0, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	score, ok := t.users[member{guildID, username}]
+	m.mu.Unlock()
+
+	if !ok {
+		_, err := m.AddUserIfNotExist(tableName, guildID, username, ctx)
+		if err != nil {
+			return 0, fmt.Errorf("there was an error creating your user: %w", err)
+		}
+		return 0, nil
+	}
+	return score, nil
+}
+
+func (m *Memory) ChangeScore(tableName string, guildID string, username string, change ScoreChange, _ context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return 0, err
+	}
+	change, err = change.validate(username)
+	if err != nil {
+		return 0, err
+	}
+
+	key := member{guildID, username}
+	previous, ok := t.users[key]
+	if !ok {
+		t.order = append(t.order, key)
+	}
+	switch change.Op {
+	case ScoreIncrement:
+		t.users[key] = previous + change.Amount
+	case ScoreDecrement:
+		t.users[key] = previous - change.Amount
+	case ScoreSet:
+		t.users[key] = change.Amount
+	}
+	m.recordScoreEvent(ScoreEvent{
+		TableName:  tableName,
+		GuildID:    guildID,
+		Username:   username,
+		Delta:      t.users[key] - previous,
+		Score:      t.users[key],
+		Reason:     change.Reason,
+		Actor:      change.Actor,
+		OnBehalfOf: change.OnBehalfOf,
+	})
+	return t.users[key], nil
+}
+
+func (m *Memory) PutAnswerInDB(tablenName string, guildID string, answer string, numberInArray int, _ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := quoteIdentifier(tablenName); err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return "", fmt.Errorf("%w: the answer cannot be empty", ErrInvalidInput)
+	}
+	t := m.createTable(tablenName)
+	t.answers[guildID] = map[string]string{
+		"answer":   answer,
+		"position": strconv.Itoa(numberInArray),
+	}
+	return fmt.Sprintf("the %s table has been updated with %s", tablenName, answer), nil
+}
+
+func (m *Memory) ReadAnswerFromDB(tableName string, guildID string, column string, _ context.Context) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return "", err
+	}
+	column, err = answerColumn(column)
+	if err != nil {
+		return "", err
+	}
+	answer, ok := t.answers[guildID][column]
+	if !ok {
+		return "", fmt.Errorf("there was an error finding the answer: %w", ErrNotFound)
+	}
+	return answer, nil
+}
+
+func (m *Memory) GetLeaderboard(tableName string, guildID string, ctx context.Context) (string, error) {
+	leaderboard, err := m.Leaderboard(tableName, guildID, LeaderboardPage{}, ctx)
+	if err != nil {
+		return "", err
+	}
+	return leaderboard.String(), nil
+}
+
+func (m *Memory) Leaderboard(tableName string, guildID string, page LeaderboardPage, _ context.Context) (Leaderboard, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return Leaderboard{}, err
+	}
+	page, err = page.validate()
+	if err != nil {
+		return Leaderboard{}, err
+	}
+
+	var entries []LeaderboardEntry
+	if page.Since.IsZero() {
+		entries = t.ranked(guildID)
+	} else {
+		scores := map[string]int{}
+		var order []string
+		for _, e := range m.events {
+			if e.TableName != tableName || e.GuildID != guildID || e.CreatedAt.Before(page.Since) {
+				continue
+			}
+			if _, ok := scores[e.Username]; !ok {
+				order = append(order, e.Username)
+			}
+			scores[e.Username] += e.Delta
+		}
+		entries = rankScores(scores, order)
+	}
+	leaderboard := Leaderboard{Total: len(entries), Page: page}
+	if page.Offset < len(entries) {
+		leaderboard.Entries = entries[page.Offset:min(page.Offset+page.Limit, len(entries))]
+	}
+	return leaderboard, nil
+}
+
+func (m *Memory) Standing(tableName string, guildID string, username string, window int, _ context.Context) (Standing, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return Standing{}, err
+	}
+	if username == "" {
+		return Standing{}, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	if err := validateStandingWindow(window); err != nil {
+		return Standing{}, err
+	}
+
+	entries := t.ranked(guildID)
+	for i, entry := range entries {
+		if entry.Username != username {
+			continue
+		}
+		lower := 0
+		for _, other := range entries {
+			if other.Score < entry.Score {
+				lower++
+			}
+		}
+		percentRank := 0.0
+		if len(entries) > 1 {
+			percentRank = float64(lower) / float64(len(entries)-1)
+		}
+		standing := Standing{
+			LeaderboardEntry: entry,
+			Percentile:       percentile(percentRank, len(entries)),
+			Total:            len(entries),
+			Above:            entries[max(i-window, 0):i],
+			Below:            entries[i+1 : min(i+1+window, len(entries))],
+		}
+		if len(standing.Above) == 0 {
+			standing.Above = nil
+		}
+		if len(standing.Below) == 0 {
+			standing.Below = nil
+		}
+		return standing, nil
+	}
+	return Standing{}, fmt.Errorf("the user %s is not on the leaderboard: %w", username, ErrNotFound)
+}
+
+// ranked returns every user in the guild ordered and ranked the same way as
+// the Postgres leaderboard queries.
+func (t *memoryTable) ranked(guildID string) []LeaderboardEntry {
+	scores := map[string]int{}
+	var order []string
+	for _, key := range t.order {
+		if key.guildID == guildID {
+			scores[key.username] = t.users[key]
+			order = append(order, key.username)
+		}
+	}
+	return rankScores(scores, order)
+}
+
+func rankScores(scores map[string]int, order []string) []LeaderboardEntry {
+	usernames := append([]string(nil), order...)
+	sort.Slice(usernames, func(i, j int) bool {
+		if scores[usernames[i]] != scores[usernames[j]] {
+			return scores[usernames[i]] > scores[usernames[j]]
+		}
+		return usernames[i] < usernames[j]
+	})
+
+	entries := make([]LeaderboardEntry, 0, len(usernames))
+	rank := 0
+	for i, username := range usernames {
+		if i == 0 || scores[username] != scores[usernames[i-1]] {
+			rank++
+		}
+		entries = append(entries, LeaderboardEntry{Rank: rank, Username: username, Score: scores[username]})
+	}
+	return entries
+}
+
+func (m *Memory) StartRound(_ context.Context, round Round) (Round, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if round.GuildID == "" || round.ChannelID == "" || round.Answer == "" {
+		return Round{}, fmt.Errorf("%w: guild_id: %s, channel_id: %s, or answer must not be empty", ErrInvalidInput, round.GuildID, round.ChannelID)
+	}
+	for i, r := range m.rounds {
+		if r.GuildID == round.GuildID && r.ChannelID == round.ChannelID && r.Status == RoundActive {
+			m.rounds[i].Status = RoundReplaced
+		}
+	}
+
+	round.ID = int64(len(m.rounds) + 1)
+	round.Status = RoundActive
+	round.StartedAt = time.Now()
+	m.rounds = append(m.rounds, round)
+	return round, nil
+}
+
+func (m *Memory) ActiveRound(_ context.Context, guildID string, channelID string) (Round, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if guildID == "" || channelID == "" {
+		return Round{}, fmt.Errorf("%w: guild_id: %s, or channel_id: %s must not be empty", ErrInvalidInput, guildID, channelID)
+	}
+	for _, r := range m.rounds {
+		if r.GuildID == guildID && r.ChannelID == channelID && r.Status == RoundActive {
+			return r, nil
+		}
+	}
+	return Round{}, fmt.Errorf("there was an error finding the active round: %w", ErrNotFound)
+}
+
+func (m *Memory) EndRound(_ context.Context, roundID int64, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, err := m.activeRound(roundID)
+	if err != nil {
+		return err
+	}
+	r.Status = status
+	return nil
+}
+
+func (m *Memory) UseHint(_ context.Context, roundID int64) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, err := m.activeRound(roundID)
+	if err != nil {
+		return 0, err
+	}
+	r.HintsUsed++
+	return r.HintsUsed, nil
+}
+
+func (m *Memory) SolveRound(_ context.Context, roundID int64, username string, points int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if username == "" {
+		return 0, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	r, err := m.activeRound(roundID)
+	if err != nil {
+		return 0, err
+	}
+	r.Status = RoundSolved
+	r.SolvedBy = username
+	r.Points = points
+
+	t := m.createTable(PokemonScoresTable)
+	key := member{r.GuildID, username}
+	if _, ok := t.users[key]; !ok {
+		t.order = append(t.order, key)
+	}
+	t.users[key] += points
+	m.recordScoreEvent(ScoreEvent{
+		TableName: PokemonScoresTable,
+		GuildID:   r.GuildID,
+		Username:  username,
+		Delta:     points,
+		Score:     t.users[key],
+		Reason:    ReasonRoundSolved,
+		Actor:     username,
+		RoundID:   roundID,
+	})
+	return t.users[key], nil
+}
+
+func (m *Memory) recordScoreEvent(event ScoreEvent) {
+	if event.Delta == 0 {
+		return
+	}
+	event.ID = int64(len(m.events) + 1)
+	event.CreatedAt = m.Now()
+	m.events = append(m.events, event)
+}
+
+func (m *Memory) ScoreHistory(tableName string, guildID string, username string, page Page, _ context.Context) (ScoreHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return ScoreHistory{}, err
+	}
+	if username == "" {
+		return ScoreHistory{}, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	page, err := page.validate()
+	if err != nil {
+		return ScoreHistory{}, err
+	}
+	if _, err := m.table(tableName); err != nil {
+		return ScoreHistory{}, err
+	}
+
+	var events []ScoreEvent
+	for i := len(m.events) - 1; i >= 0; i-- {
+		if e := m.events[i]; e.TableName == tableName && e.GuildID == guildID && e.Username == username {
+			events = append(events, e)
+		}
+	}
+	history := ScoreHistory{Total: len(events), Page: page}
+	if page.Offset < len(events) {
+		history.Events = events[page.Offset:min(page.Offset+page.Limit, len(events))]
+	}
+	return history, nil
+}
+
+func (m *Memory) activeRound(roundID int64) (*Round, error) {
+	for i := range m.rounds {
+		if m.rounds[i].ID == roundID {
+			if m.rounds[i].Status != RoundActive {
+				break
+			}
+			return &m.rounds[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the round %d is no longer active: %w", roundID, ErrConflict)
+}
+
+func (m *Memory) LoadPokemon(_ context.Context) ([]Pokemon, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pokemon := make([]Pokemon, 0, len(m.pokemon))
+	for _, pm := range m.pokemon {
+		pokemon = append(pokemon, pm)
+	}
+	sort.Slice(pokemon, func(i, j int) bool {
+		return pokemon[i].Number < pokemon[j].Number
+	})
+	return pokemon, nil
+}
+
+func (m *Memory) SavePokemon(_ context.Context, pokemon []Pokemon) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, pm := range pokemon {
+		m.pokemon[pm.Number] = pm
+	}
+	return nil
+}
+
+func (m *Memory) IdentifyUser(tableName string, guildID string, discordID string, username string, _ context.Context) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return User{}, err
+	}
+	if err := validateUser(discordID, username); err != nil {
+		return User{}, err
+	}
+
+	user, ok := m.users[discordID]
+	if !ok {
+		user = &User{ID: int64(len(m.users) + 1), DiscordID: discordID}
+		m.users[discordID] = user
+	}
+	if !ok || user.Name != username {
+		user.Name = username
+		user.Names = append(user.Names, UserName{Name: username, SeenAt: m.Now()})
+	}
+
+	key := member{guildID, username}
+	for row, owner := range t.owners {
+		if row.guildID != guildID || owner != user.ID || row.username == username {
+			continue
+		}
+		// A renamed user's row and history follow them to the new name.
+		if _, taken := t.users[key]; taken {
+			return User{}, fmt.Errorf("%s already has a score of their own, merge it into %s first: %w", username, row.username, ErrConflict)
+		}
+		t.rename(row, key)
+		m.moveScoreEvents(tableName, guildID, row.username, username)
+		return m.userCopy(user), nil
+	}
+
+	if owner, ok := t.owners[key]; ok && owner != user.ID {
+		return User{}, fmt.Errorf("the name %s belongs to another user: %w", username, ErrConflict)
+	}
+	if _, ok := t.users[key]; !ok {
+		t.users[key] = 0
+		t.order = append(t.order, key)
+	}
+	t.owners[key] = user.ID
+	return m.userCopy(user), nil
+}
+
+// userCopy is user without its name history, as IdentifyUser returns it.
+func (m *Memory) userCopy(user *User) User {
+	return User{ID: user.ID, DiscordID: user.DiscordID, Name: user.Name}
+}
+
+func (m *Memory) GetUser(discordID string, _ context.Context) (User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if discordID == "" {
+		return User{}, fmt.Errorf("%w: the user_id must not be empty", ErrInvalidInput)
+	}
+	user, ok := m.users[discordID]
+	if !ok {
+		return User{}, fmt.Errorf("there was an error finding the user: %w", ErrNotFound)
+	}
+	found := *user
+	found.Names = append([]UserName(nil), user.Names...)
+	return found, nil
+}
+
+func (m *Memory) MergeUsers(tableName string, guildID string, from string, into string, _ context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return 0, err
+	}
+	if from == "" || into == "" || from == into {
+		return 0, fmt.Errorf("%w: from: %s, and into: %s must be two different users", ErrInvalidInput, from, into)
+	}
+	source, target := member{guildID, from}, member{guildID, into}
+	if _, ok := t.users[source]; !ok {
+		return 0, fmt.Errorf("the user %s does not exist: %w", from, ErrNotFound)
+	}
+	if _, ok := t.users[target]; !ok {
+		return 0, fmt.Errorf("the user %s does not exist: %w", into, ErrNotFound)
+	}
+	sourceOwner, sourceOK := t.owners[source]
+	targetOwner, targetOK := t.owners[target]
+	if sourceOK && targetOK && sourceOwner != targetOwner {
+		return 0, fmt.Errorf("%s and %s are different users: %w", from, into, ErrConflict)
+	}
+
+	t.users[target] += t.users[source]
+	if sourceOK && !targetOK {
+		t.owners[target] = sourceOwner
+	}
+	t.remove(source)
+	m.moveScoreEvents(tableName, guildID, from, into)
+	return t.users[target], nil
+}
+
+// rename moves a row to a new key, keeping its place in the table.
+func (t *memoryTable) rename(from member, to member) {
+	t.users[to] = t.users[from]
+	if owner, ok := t.owners[from]; ok {
+		t.owners[to] = owner
+	}
+	delete(t.users, from)
+	delete(t.owners, from)
+	for i, key := range t.order {
+		if key == from {
+			t.order[i] = to
+		}
+	}
+}
+
+func (t *memoryTable) remove(row member) {
+	delete(t.users, row)
+	delete(t.owners, row)
+	for i, key := range t.order {
+		if key == row {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			break
+		}
+	}
+}
+
+func (m *Memory) moveScoreEvents(tableName string, guildID string, from string, to string) {
+	for i, e := range m.events {
+		if e.TableName == tableName && e.GuildID == guildID && e.Username == from {
+			m.events[i].Username = to
+		}
+	}
+}
+
+func (m *Memory) ExportScores(tableName string, guildID string, fn func(ScoreRow) error, _ context.Context) error {
+	m.mu.Lock()
+	t, err := m.table(tableName)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	rows := make([]ScoreRow, 0, len(t.order))
+	for _, key := range t.order {
+		if guildID != AllGuilds && key.guildID != guildID {
+			continue
+		}
+		rows = append(rows, ScoreRow{GuildID: key.guildID, Username: key.username, Score: t.users[key]})
+	}
+	m.mu.Unlock()
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].GuildID != rows[j].GuildID {
+			return rows[i].GuildID < rows[j].GuildID
+		}
+		return rows[i].Username < rows[j].Username
+	})
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ImportScores(tableName string, guildID string, rows []ScoreRow, _ context.Context) (ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err := m.table(tableName)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	valid, errs := validateScoreRows(rows, guildID)
+	for _, row := range valid {
+		key := member{row.GuildID, row.Username}
+		previous, ok := t.users[key]
+		if !ok {
+			t.order = append(t.order, key)
+		}
+		t.users[key] = row.Score
+		m.recordScoreEvent(ScoreEvent{
+			TableName: tableName,
+			GuildID:   row.GuildID,
+			Username:  row.Username,
+			Delta:     row.Score - previous,
+			Score:     row.Score,
+			Reason:    ReasonImport,
+		})
+	}
+	return ImportResult{Imported: len(valid), Errors: errs}, nil
+}
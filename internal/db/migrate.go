@@ -0,0 +1,270 @@
+package db
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey is the advisory lock held while migrations run so that
+// replicas starting together apply them one at a time.
+const migrationLockKey = 7_283_114_001
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations directory and returns every
+// migration in version order. Each version must have both an up and a down
+// file.
+func loadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the migrations: %w", err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("the migration file %s is not named <version>_<name>.<up|down>.sql", entry.Name())
+		}
+		version, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("the migration file %s has an invalid version: %w", entry.Name(), err)
+		}
+		sql, err := fs.ReadFile(migrationFiles, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("there was an error reading the migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("the migration version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("the migration %04d_%s must have both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// MigrateUp applies every migration that has not been applied yet and
+// returns the ones it ran.
+func (p *Postgres) MigrateUp(ctx context.Context) (applied []Migration, err error) {
+	ctx, span := o11y.StartSpan(ctx, "db: migrate up")
+	defer o11y.End(span, &err)
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			err := runMigration(ctx, conn, m.Up, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("there was an error applying migration %04d_%s: %w", m.Version, m.Name, wrapPgError(err))
+			}
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	o11y.AddField(ctx, "migrations_applied", len(applied))
+	return applied, err
+}
+
+// MigrateDown rolls back the most recently applied migrations, up to steps of
+// them, and returns the ones it rolled back.
+func (p *Postgres) MigrateDown(ctx context.Context, steps int) (reverted []Migration, err error) {
+	ctx, span := o11y.StartSpan(ctx, "db: migrate down")
+	defer o11y.End(span, &err)
+
+	if steps < 1 {
+		return nil, fmt.Errorf("%w: steps must be at least 1, got %d", ErrInvalidInput, steps)
+	}
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			err := runMigration(ctx, conn, m.Down, func(tx pgx.Tx) error {
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("there was an error reverting migration %04d_%s: %w", m.Version, m.Name, wrapPgError(err))
+			}
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	o11y.AddField(ctx, "migrations_reverted", len(reverted))
+	return reverted, err
+}
+
+// MigrationStatus lists every known migration along with when it was
+// applied, or a nil AppliedAt if it is still pending.
+func (p *Postgres) MigrationStatus(ctx context.Context) (status []MigrationStatus, err error) {
+	ctx, span := o11y.StartSpan(ctx, "db: migrate status")
+	defer o11y.End(span, &err)
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	err = p.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			s := MigrationStatus{Version: m.Version, Name: m.Name}
+			if appliedAt, ok := done[m.Version]; ok {
+				s.AppliedAt = &appliedAt
+			}
+			status = append(status, s)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// withMigrationLock runs f on a single connection while holding the migration
+// advisory lock. The schema_migrations table is created first if needed.
+func (p *Postgres) withMigrationLock(ctx context.Context, f func(conn *pgxpool.Conn) error) error {
+	conn, err := p.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("there was an error connecting to the database: %w: %w", ErrUnavailable, err)
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey)
+	if err != nil {
+		return fmt.Errorf("there was an error taking the migration lock: %w", wrapPgError(err))
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx is done.
+		_, _ = conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}()
+
+	_, err = conn.Exec(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		);
+	`)
+	if err != nil {
+		return fmt.Errorf("there was an error creating the schema_migrations table: %w", wrapPgError(err))
+	}
+
+	return f(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *pgxpool.Conn) (map[int]time.Time, error) {
+	rows, err := conn.Query(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error reading the applied migrations: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	done := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		err := rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning row: %w", err)
+		}
+		done[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return done, nil
+}
+
+// runMigration runs sql and record in a single transaction so a migration is
+// never left half applied.
+func runMigration(ctx context.Context, conn *pgxpool.Conn, sql string, record func(tx pgx.Tx) error) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, sql)
+	if err != nil {
+		return err
+	}
+	err = record(tx)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS pokemon_scores;
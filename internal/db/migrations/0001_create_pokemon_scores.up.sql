@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS pokemon_scores (
+	id SERIAL PRIMARY KEY
+);
+
+ALTER TABLE pokemon_scores
+	ADD COLUMN IF NOT EXISTS username TEXT UNIQUE,
+	ADD COLUMN IF NOT EXISTS score INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- The backfilled columns may hold scores by now, so they are left in place.
+SELECT 1;
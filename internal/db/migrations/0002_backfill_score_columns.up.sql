@@ -0,0 +1,22 @@
+-- Tables made through create_table used to have their username and score
+-- columns added the first time a user was written to them. New tables are
+-- created with those columns, this brings the older ones into line.
+DO $$
+DECLARE
+	t record;
+BEGIN
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'name'
+			AND tb.table_type = 'BASE TABLE'
+	LOOP
+		EXECUTE format(
+			'ALTER TABLE %I ADD COLUMN IF NOT EXISTS "username" VARCHAR(255), ADD COLUMN IF NOT EXISTS "score" INTEGER',
+			t.table_name
+		);
+	END LOOP;
+END $$;
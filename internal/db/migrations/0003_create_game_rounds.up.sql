@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS game_rounds (
+	id BIGSERIAL PRIMARY KEY,
+	guild_id TEXT NOT NULL,
+	channel_id TEXT NOT NULL,
+	answer TEXT NOT NULL,
+	pokedex_number INTEGER NOT NULL,
+	status TEXT NOT NULL DEFAULT 'active',
+	started_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	ended_at TIMESTAMPTZ,
+	solved_by TEXT,
+	points INTEGER
+);
+
+-- Only one round can be running in a channel at a time.
+CREATE UNIQUE INDEX IF NOT EXISTS game_rounds_active_channel
+	ON game_rounds (guild_id, channel_id)
+	WHERE status = 'active';
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS pokemon_catalog (
+	number INTEGER PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	types TEXT[] NOT NULL DEFAULT '{}',
+	generation INTEGER NOT NULL,
+	legendary BOOLEAN NOT NULL DEFAULT false,
+	base_experience INTEGER NOT NULL DEFAULT 0
+);
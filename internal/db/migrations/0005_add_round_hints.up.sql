@@ -0,0 +1 @@
+ALTER TABLE game_rounds ADD COLUMN IF NOT EXISTS hints_used INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+-- An append-only log of every change to a score, so leaderboards can be
+-- worked out for any period. The score columns stay as the running total.
+CREATE TABLE IF NOT EXISTS score_events (
+	id BIGSERIAL PRIMARY KEY,
+	table_name TEXT NOT NULL,
+	username TEXT NOT NULL,
+	delta INTEGER NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS score_events_table_created_at
+	ON score_events (table_name, created_at);
@@ -0,0 +1,3 @@
+-- The unique indexes are left in place, dropping them would only let
+-- duplicate users back in.
+SELECT 1;
@@ -0,0 +1,29 @@
+-- Scores are now changed with INSERT ... ON CONFLICT ("username"), which needs
+-- usernames to be unique in every score table. Where a user was added more
+-- than once only their highest scoring row is kept.
+DO $$
+DECLARE
+	t record;
+BEGIN
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'username'
+			AND tb.table_type = 'BASE TABLE'
+			AND c.table_name NOT IN ('schema_migrations', 'game_rounds', 'pokemon_catalog', 'score_events')
+	LOOP
+		EXECUTE format(
+			'DELETE FROM %I a USING %I b
+			WHERE a."username" = b."username"
+				AND (COALESCE(a."score", 0), a.ctid) < (COALESCE(b."score", 0), b.ctid)',
+			t.table_name, t.table_name
+		);
+		EXECUTE format(
+			'CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I ("username")',
+			left(t.table_name, 50) || '_username_key', t.table_name
+		);
+	END LOOP;
+END $$;
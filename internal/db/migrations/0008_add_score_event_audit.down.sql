@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS score_events_history;
+
+ALTER TABLE score_events
+	DROP COLUMN IF EXISTS score,
+	DROP COLUMN IF EXISTS reason,
+	DROP COLUMN IF EXISTS actor,
+	DROP COLUMN IF EXISTS round_id;
@@ -0,0 +1,8 @@
+ALTER TABLE score_events
+	ADD COLUMN IF NOT EXISTS score INTEGER,
+	ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS actor TEXT NOT NULL DEFAULT '',
+	ADD COLUMN IF NOT EXISTS round_id BIGINT REFERENCES game_rounds (id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS score_events_history
+	ON score_events (table_name, username, id DESC);
@@ -0,0 +1,3 @@
+-- Putting guilds back together could break unique usernames and leave more
+-- than one answer per table, so the guild columns are left in place.
+SELECT 1;
@@ -0,0 +1,79 @@
+-- Scores, answers and their history are kept per Discord guild. Rows from
+-- before guilds were tracked, and clients that send no guild, share the
+-- empty guild_id.
+DO $$
+DECLARE
+	t record;
+	idx record;
+BEGIN
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'username'
+			AND tb.table_type = 'BASE TABLE'
+			AND c.table_name NOT IN ('schema_migrations', 'game_rounds', 'pokemon_catalog', 'score_events')
+	LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS "guild_id" TEXT NOT NULL DEFAULT %L', t.table_name, '');
+
+		-- Usernames are now only unique within a guild.
+		FOR idx IN
+			SELECT i.indexrelid::regclass AS index_name, con.conname
+			FROM pg_index i
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = i.indkey[0]
+			LEFT JOIN pg_constraint con ON con.conindid = i.indexrelid AND con.conrelid = i.indrelid
+			WHERE i.indrelid = format('%I', t.table_name)::regclass
+				AND i.indisunique
+				AND i.indnatts = 1
+				AND a.attname = 'username'
+		LOOP
+			IF idx.conname IS NOT NULL THEN
+				EXECUTE format('ALTER TABLE %I DROP CONSTRAINT %I', t.table_name, idx.conname);
+			ELSE
+				EXECUTE format('DROP INDEX %s', idx.index_name);
+			END IF;
+		END LOOP;
+
+		EXECUTE format(
+			'CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I ("guild_id", "username")',
+			left(t.table_name, 44) || '_guild_username_key', t.table_name
+		);
+	END LOOP;
+
+	-- Answer tables held a single row at id 1. That row becomes the answer
+	-- for the empty guild, and other guilds get a row each.
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'ANSWER'
+			AND tb.table_type = 'BASE TABLE'
+	LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS "guild_id" TEXT NOT NULL DEFAULT %L', t.table_name, '');
+		EXECUTE format(
+			'CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I ("guild_id")',
+			left(t.table_name, 53) || '_guild_key', t.table_name
+		);
+		-- The row at id 1 was inserted with an explicit id, so move the
+		-- sequence past it before new rows take ids from it.
+		EXECUTE format(
+			'SELECT setval(pg_get_serial_sequence(%L, %L), GREATEST(COALESCE(max("id"), 0), 1)) FROM %I',
+			quote_ident(t.table_name), 'id', t.table_name
+		);
+	END LOOP;
+END $$;
+
+ALTER TABLE score_events ADD COLUMN IF NOT EXISTS guild_id TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS score_events_table_created_at;
+DROP INDEX IF EXISTS score_events_history;
+
+CREATE INDEX IF NOT EXISTS score_events_guild_created_at
+	ON score_events (table_name, guild_id, created_at);
+
+CREATE INDEX IF NOT EXISTS score_events_guild_history
+	ON score_events (table_name, guild_id, username, id DESC);
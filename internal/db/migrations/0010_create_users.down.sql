@@ -0,0 +1,20 @@
+DO $$
+DECLARE
+	t record;
+BEGIN
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'user_id'
+			AND tb.table_type = 'BASE TABLE'
+			AND c.table_name <> 'user_names'
+	LOOP
+		EXECUTE format('ALTER TABLE %I DROP COLUMN IF EXISTS "user_id"', t.table_name);
+	END LOOP;
+END $$;
+
+DROP TABLE IF EXISTS user_names;
+DROP TABLE IF EXISTS users;
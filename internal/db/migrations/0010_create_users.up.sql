@@ -0,0 +1,42 @@
+-- Users are keyed by their Discord user ID, which survives renames. Score
+-- rows keep their username as the name to show, and point at the user they
+-- belong to once the bot has sent that user's ID.
+CREATE TABLE IF NOT EXISTS users (
+	id BIGSERIAL PRIMARY KEY,
+	discord_id TEXT NOT NULL UNIQUE,
+	display_name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+-- Every name a user has been seen with, oldest first.
+CREATE TABLE IF NOT EXISTS user_names (
+	id BIGSERIAL PRIMARY KEY,
+	user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+	display_name TEXT NOT NULL,
+	seen_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS user_names_user_id ON user_names (user_id, id);
+
+DO $$
+DECLARE
+	t record;
+BEGIN
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'username'
+			AND tb.table_type = 'BASE TABLE'
+			AND c.table_name NOT IN ('schema_migrations', 'game_rounds', 'pokemon_catalog', 'score_events', 'users', 'user_names')
+	LOOP
+		EXECUTE format('ALTER TABLE %I ADD COLUMN IF NOT EXISTS "user_id" BIGINT REFERENCES users (id) ON DELETE SET NULL', t.table_name);
+		EXECUTE format(
+			'CREATE UNIQUE INDEX IF NOT EXISTS %I ON %I ("guild_id", "user_id")',
+			left(t.table_name, 45) || '_guild_user_id_key', t.table_name
+		);
+	END LOOP;
+END $$;
@@ -0,0 +1,36 @@
+ALTER TABLE rounds DROP COLUMN IF EXISTS game_id;
+ALTER INDEX IF EXISTS rounds_active_channel RENAME TO game_rounds_active_channel;
+ALTER TABLE rounds RENAME TO game_rounds;
+
+-- Each game goes back to a table of its own, holding its answers if it has
+-- any and its scores otherwise.
+DO $$
+DECLARE
+	g record;
+BEGIN
+	FOR g IN SELECT id, name FROM games LOOP
+		IF EXISTS (SELECT 1 FROM answers WHERE game_id = g.id) AND NOT EXISTS (SELECT 1 FROM scores WHERE game_id = g.id) THEN
+			EXECUTE format(
+				'CREATE TABLE %I (id SERIAL PRIMARY KEY, name TEXT, "guild_id" TEXT NOT NULL DEFAULT %L UNIQUE, "ANSWER" VARCHAR(255), "POSITION" INTEGER)',
+				g.name, ''
+			);
+			EXECUTE format(
+				'INSERT INTO %I ("guild_id", "ANSWER", "POSITION") SELECT guild_id, answer, position FROM answers WHERE game_id = %s',
+				g.name, g.id
+			);
+		ELSE
+			EXECUTE format(
+				'CREATE TABLE %I (id SERIAL PRIMARY KEY, name TEXT, "guild_id" TEXT NOT NULL DEFAULT %L, "username" VARCHAR(255), "score" INTEGER, "user_id" BIGINT REFERENCES users (id) ON DELETE SET NULL, UNIQUE ("guild_id", "username"), UNIQUE ("guild_id", "user_id"))',
+				g.name, ''
+			);
+			EXECUTE format(
+				'INSERT INTO %I ("guild_id", "username", "score", "user_id") SELECT guild_id, username, score, user_id FROM scores WHERE game_id = %s',
+				g.name, g.id
+			);
+		END IF;
+	END LOOP;
+END $$;
+
+DROP TABLE IF EXISTS answers;
+DROP TABLE IF EXISTS scores;
+DROP TABLE IF EXISTS games;
@@ -0,0 +1,90 @@
+-- Every game used to keep its scores or its answer in a table of its own,
+-- made on demand through create_table. They are moved into a fixed set of
+-- tables, with the old table name kept as the game's name so callers that
+-- pass a tablename keep working.
+CREATE TABLE IF NOT EXISTS games (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS scores (
+	id BIGSERIAL PRIMARY KEY,
+	game_id BIGINT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	guild_id TEXT NOT NULL DEFAULT '',
+	username TEXT NOT NULL,
+	score INTEGER NOT NULL DEFAULT 0,
+	user_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+	UNIQUE (game_id, guild_id, username),
+	UNIQUE (game_id, guild_id, user_id)
+);
+
+-- The answer a guild is currently playing for in a game.
+CREATE TABLE IF NOT EXISTS answers (
+	game_id BIGINT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+	guild_id TEXT NOT NULL DEFAULT '',
+	answer TEXT NOT NULL,
+	position INTEGER,
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (game_id, guild_id)
+);
+
+INSERT INTO games (name) VALUES ('pokemon_scores') ON CONFLICT (name) DO NOTHING;
+
+DO $$
+DECLARE
+	t record;
+BEGIN
+	-- Answer tables were given username and score columns along with every
+	-- other table, so they are moved first, before the scores loop below
+	-- would find and drop them.
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'ANSWER'
+			AND tb.table_type = 'BASE TABLE'
+	LOOP
+		INSERT INTO games (name) VALUES (t.table_name) ON CONFLICT (name) DO NOTHING;
+		EXECUTE format(
+			'INSERT INTO answers (game_id, guild_id, answer, position)
+			SELECT g.id, a."guild_id", a."ANSWER", a."POSITION"
+			FROM %I a, games g
+			WHERE g.name = %L AND a."ANSWER" IS NOT NULL
+			ON CONFLICT DO NOTHING',
+			t.table_name, t.table_name
+		);
+		EXECUTE format('DROP TABLE %I', t.table_name);
+	END LOOP;
+
+	FOR t IN
+		SELECT c.table_name
+		FROM information_schema.columns c
+		JOIN information_schema.tables tb
+			ON tb.table_schema = c.table_schema AND tb.table_name = c.table_name
+		WHERE c.table_schema = 'public'
+			AND c.column_name = 'username'
+			AND tb.table_type = 'BASE TABLE'
+			AND c.table_name NOT IN ('schema_migrations', 'game_rounds', 'pokemon_catalog', 'score_events', 'users', 'user_names', 'games', 'scores', 'answers')
+	LOOP
+		INSERT INTO games (name) VALUES (t.table_name) ON CONFLICT (name) DO NOTHING;
+		EXECUTE format(
+			'INSERT INTO scores (game_id, guild_id, username, score, user_id)
+			SELECT g.id, s."guild_id", s."username", COALESCE(s."score", 0), s."user_id"
+			FROM %I s, games g
+			WHERE g.name = %L AND s."username" IS NOT NULL
+			ON CONFLICT DO NOTHING',
+			t.table_name, t.table_name
+		);
+		EXECUTE format('DROP TABLE %I', t.table_name);
+	END LOOP;
+END $$;
+
+-- Rounds are of a game, which is where their points go.
+ALTER TABLE game_rounds RENAME TO rounds;
+ALTER INDEX IF EXISTS game_rounds_active_channel RENAME TO rounds_active_channel;
+ALTER TABLE rounds ADD COLUMN IF NOT EXISTS game_id BIGINT REFERENCES games (id) ON DELETE CASCADE;
+UPDATE rounds SET game_id = (SELECT id FROM games WHERE name = 'pokemon_scores') WHERE game_id IS NULL;
+ALTER TABLE rounds ALTER COLUMN game_id SET NOT NULL;
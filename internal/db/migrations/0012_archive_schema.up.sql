@@ -0,0 +1,14 @@
+-- Deleted games are moved here rather than dropped, so they can be looked at
+-- or restored by hand. The tables copy the columns of the live ones without
+-- their keys, so a name can be archived more than once; columns added to the
+-- live tables must be added here too.
+CREATE SCHEMA IF NOT EXISTS archive;
+
+CREATE TABLE IF NOT EXISTS archive.games (
+	LIKE public.games,
+	archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS archive.scores (LIKE public.scores);
+CREATE TABLE IF NOT EXISTS archive.answers (LIKE public.answers);
+CREATE TABLE IF NOT EXISTS archive.rounds (LIKE public.rounds);
+CREATE TABLE IF NOT EXISTS archive.score_events (LIKE public.score_events);
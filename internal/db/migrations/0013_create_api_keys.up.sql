@@ -0,0 +1,11 @@
+-- Keys clients authenticate with. Only a SHA-256 hash of each key is kept,
+-- along with a prefix of it to find the key by and to name it in the CLI.
+CREATE TABLE IF NOT EXISTS api_keys (
+	id BIGSERIAL PRIMARY KEY,
+	client TEXT NOT NULL,
+	prefix TEXT NOT NULL UNIQUE,
+	hash BYTEA NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
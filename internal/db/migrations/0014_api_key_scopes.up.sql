@@ -0,0 +1,5 @@
+-- What each key is allowed to do. Keys made before scopes were checked get
+-- the bot role, and keys that need to change scores or tables are minted
+-- again with the scopes they need.
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+UPDATE api_keys SET scopes = ARRAY['read:leaderboard', 'game:play'];
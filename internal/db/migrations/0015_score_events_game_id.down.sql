@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS score_events_game_created_at;
+DROP INDEX IF EXISTS score_events_game_history;
+
+CREATE INDEX IF NOT EXISTS score_events_guild_created_at
+	ON score_events (table_name, guild_id, created_at);
+
+CREATE INDEX IF NOT EXISTS score_events_guild_history
+	ON score_events (table_name, guild_id, username, id DESC);
+
+ALTER TABLE score_events DROP COLUMN IF EXISTS game_id;
+ALTER TABLE archive.score_events DROP COLUMN IF EXISTS game_id;
@@ -0,0 +1,25 @@
+-- Score events were only tied to their game by its name. They now point at
+-- the game itself, so they go with it and can not be confused with a later
+-- game of the same name.
+ALTER TABLE score_events ADD COLUMN IF NOT EXISTS game_id BIGINT REFERENCES games (id) ON DELETE CASCADE;
+ALTER TABLE archive.score_events ADD COLUMN IF NOT EXISTS game_id BIGINT;
+
+UPDATE score_events e SET game_id = g.id
+FROM games g
+WHERE g.name = e.table_name AND e.game_id IS NULL;
+
+-- Events left over from tables deleted before they were archived have no
+-- game, so they are archived now.
+INSERT INTO archive.score_events SELECT * FROM score_events WHERE game_id IS NULL;
+DELETE FROM score_events WHERE game_id IS NULL;
+
+ALTER TABLE score_events ALTER COLUMN game_id SET NOT NULL;
+
+DROP INDEX IF EXISTS score_events_guild_created_at;
+DROP INDEX IF EXISTS score_events_guild_history;
+
+CREATE INDEX IF NOT EXISTS score_events_game_created_at
+	ON score_events (game_id, guild_id, created_at);
+
+CREATE INDEX IF NOT EXISTS score_events_game_history
+	ON score_events (game_id, guild_id, username, id DESC);
@@ -0,0 +1,2 @@
+ALTER TABLE score_events DROP COLUMN IF EXISTS on_behalf_of;
+ALTER TABLE archive.score_events DROP COLUMN IF EXISTS on_behalf_of;
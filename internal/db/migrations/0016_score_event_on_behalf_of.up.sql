@@ -0,0 +1,5 @@
+-- The actor of a score change is the client that made it, now that clients
+-- are authenticated. Who the client said it was acting for is kept beside
+-- it rather than in its place.
+ALTER TABLE score_events ADD COLUMN IF NOT EXISTS on_behalf_of TEXT NOT NULL DEFAULT '';
+ALTER TABLE archive.score_events ADD COLUMN IF NOT EXISTS on_behalf_of TEXT NOT NULL DEFAULT '';
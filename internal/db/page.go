@@ -0,0 +1,34 @@
+package db
+
+import "fmt"
+
+const (
+	DefaultPageLimit = 10
+	MaxPageLimit     = 100
+)
+
+// Page picks out part of a list. A Limit of 0 means DefaultPageLimit.
+type Page struct {
+	Offset int
+	Limit  int
+}
+
+func (p Page) validate() (Page, error) {
+	if p.Limit == 0 {
+		p.Limit = DefaultPageLimit
+	}
+	if p.Offset < 0 || p.Limit < 0 || p.Limit > MaxPageLimit {
+		return Page{}, fmt.Errorf("%w: offset: %d must not be negative and limit: %d must be between 1 and %d", ErrInvalidInput, p.Offset, p.Limit, MaxPageLimit)
+	}
+	return p, nil
+}
+
+// nextOffset is where the page after this one starts, given it held count
+// of total items, or 0 if this is the last page.
+func (p Page) nextOffset(count int, total int) int {
+	next := p.Offset + count
+	if count == 0 || next >= total {
+		return 0
+	}
+	return next
+}
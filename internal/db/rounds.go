@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// PokemonScoresTable is the game that rounds are played in, and where the
+// points from solving them are awarded.
+const PokemonScoresTable = "pokemon_scores"
+
+const (
+	RoundActive   = "active"
+	RoundSolved   = "solved"
+	RoundExpired  = "expired"
+	RoundReplaced = "replaced"
+)
+
+// Round is a single Pokémon guessing round in a Discord channel. Answer is
+// never sent to clients until the round has ended.
+type Round struct {
+	ID            int64
+	GuildID       string
+	ChannelID     string
+	Answer        string
+	PokedexNumber int
+	Status        string
+	StartedAt     time.Time
+	ExpiresAt     time.Time
+	SolvedBy      string
+	Points        int
+	HintsUsed     int
+}
+
+func (p *Postgres) StartRound(ctx context.Context, round Round) (Round, error) {
+	if round.GuildID == "" || round.ChannelID == "" || round.Answer == "" {
+		return Round{}, fmt.Errorf("%w: guild_id: %s, channel_id: %s, or answer must not be empty", ErrInvalidInput, round.GuildID, round.ChannelID)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return Round{}, fmt.Errorf("there was an error starting the round: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rounds SET status = $3, ended_at = now()
+		WHERE guild_id = $1 AND channel_id = $2 AND status = $4`,
+		round.GuildID, round.ChannelID, RoundReplaced, RoundActive)
+	if err != nil {
+		return Round{}, fmt.Errorf("there was an error replacing the previous round: %w", wrapPgError(err))
+	}
+
+	game, err := ensureGame(ctx, tx, PokemonScoresTable)
+	if err != nil {
+		return Round{}, err
+	}
+	round.Status = RoundActive
+	err = tx.QueryRow(ctx, `
+		INSERT INTO rounds (game_id, guild_id, channel_id, answer, pokedex_number, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, started_at`,
+		game, round.GuildID, round.ChannelID, round.Answer, round.PokedexNumber, round.Status, round.ExpiresAt,
+	).Scan(&round.ID, &round.StartedAt)
+	if err != nil {
+		return Round{}, fmt.Errorf("there was an error creating the round: %w", wrapPgError(err))
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return Round{}, fmt.Errorf("there was an error starting the round: %w", wrapPgError(err))
+	}
+	return round, nil
+}
+
+func (p *Postgres) ActiveRound(ctx context.Context, guildID string, channelID string) (Round, error) {
+	if guildID == "" || channelID == "" {
+		return Round{}, fmt.Errorf("%w: guild_id: %s, or channel_id: %s must not be empty", ErrInvalidInput, guildID, channelID)
+	}
+
+	var round Round
+	err := p.pool.QueryRow(ctx, `
+		SELECT id, guild_id, channel_id, answer, pokedex_number, status, started_at, expires_at, hints_used
+		FROM rounds
+		WHERE guild_id = $1 AND channel_id = $2 AND status = $3`,
+		guildID, channelID, RoundActive,
+	).Scan(&round.ID, &round.GuildID, &round.ChannelID, &round.Answer, &round.PokedexNumber, &round.Status, &round.StartedAt, &round.ExpiresAt, &round.HintsUsed)
+	if err != nil {
+		return Round{}, fmt.Errorf("there was an error finding the active round: %w", wrapPgError(err))
+	}
+	return round, nil
+}
+
+func (p *Postgres) EndRound(ctx context.Context, roundID int64, status string) error {
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE rounds SET status = $2, ended_at = now()
+		WHERE id = $1 AND status = $3`,
+		roundID, status, RoundActive)
+	if err != nil {
+		return fmt.Errorf("there was an error ending the round: %w", wrapPgError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("the round %d is no longer active: %w", roundID, ErrConflict)
+	}
+	return nil
+}
+
+// UseHint records that another hint was given out for the round and returns
+// how many have been used.
+func (p *Postgres) UseHint(ctx context.Context, roundID int64) (int, error) {
+	var hintsUsed int
+	err := p.pool.QueryRow(ctx, `
+		UPDATE rounds SET hints_used = hints_used + 1
+		WHERE id = $1 AND status = $2
+		RETURNING hints_used`,
+		roundID, RoundActive,
+	).Scan(&hintsUsed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("the round %d is no longer active: %w", roundID, ErrConflict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("there was an error using a hint: %w", wrapPgError(err))
+	}
+	return hintsUsed, nil
+}
+
+// SolveRound marks the round as solved by username and adds points to their
+// score in the round's guild in the same transaction, returning their new
+// score. Only the first
+// caller to solve a round gets the points, anyone after gets ErrConflict.
+func (p *Postgres) SolveRound(ctx context.Context, roundID int64, username string, points int) (int, error) {
+	if username == "" {
+		return 0, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error solving the round: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	var guildID string
+	var game int64
+	err = tx.QueryRow(ctx, `
+		UPDATE rounds SET status = $2, solved_by = $3, points = $4, ended_at = now()
+		WHERE id = $1 AND status = $5
+		RETURNING guild_id, game_id`,
+		roundID, RoundSolved, username, points, RoundActive,
+	).Scan(&guildID, &game)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("the round %d is no longer active: %w", roundID, ErrConflict)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("there was an error solving the round: %w", wrapPgError(err))
+	}
+
+	var score int
+	err = tx.QueryRow(ctx, `
+		INSERT INTO scores AS s (game_id, guild_id, username, score) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (game_id, guild_id, username) DO UPDATE SET score = s.score + EXCLUDED.score
+		RETURNING score`,
+		game, guildID, username, points,
+	).Scan(&score)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error awarding points: %w", wrapPgError(err))
+	}
+	err = recordScoreEvent(ctx, tx, game, ScoreEvent{
+		TableName: PokemonScoresTable,
+		GuildID:   guildID,
+		Username:  username,
+		Delta:     points,
+		Score:     score,
+		Reason:    ReasonRoundSolved,
+		Actor:     username,
+		RoundID:   roundID,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error solving the round: %w", wrapPgError(err))
+	}
+	return score, nil
+}
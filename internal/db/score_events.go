@@ -0,0 +1,202 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Period is how far back a leaderboard looks. Periods other than
+// PeriodAllTime start on a UTC calendar boundary, so a weekly leaderboard
+// covers Monday to Sunday.
+type Period string
+
+const (
+	PeriodAllTime Period = "all-time"
+	PeriodDaily   Period = "daily"
+	PeriodWeekly  Period = "weekly"
+	PeriodMonthly Period = "monthly"
+)
+
+func ParsePeriod(s string) (Period, error) {
+	switch period := Period(s); period {
+	case "":
+		return PeriodAllTime, nil
+	case PeriodAllTime, PeriodDaily, PeriodWeekly, PeriodMonthly:
+		return period, nil
+	default:
+		return "", fmt.Errorf("%w: period must be %s, %s, %s or %s, got %q", ErrInvalidInput, PeriodDaily, PeriodWeekly, PeriodMonthly, PeriodAllTime, s)
+	}
+}
+
+// Since is when the period that now falls in started. It is the zero time for
+// PeriodAllTime.
+func (p Period) Since(now time.Time) time.Time {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodDaily:
+		return day
+	case PeriodWeekly:
+		// Go's weeks start on a Sunday.
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonthly:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return time.Time{}
+	}
+}
+
+// ReasonRoundSolved is the reason logged for points won in a game round.
+const ReasonRoundSolved = "round solved"
+
+// ScoreEvent is one change to a user's score. Score is what it was changed
+// to, which is 0 for changes logged before that was kept. RoundID is set
+// when the change came from a game round.
+type ScoreEvent struct {
+	ID         int64
+	TableName  string
+	GuildID    string
+	Username   string
+	Delta      int
+	Score      int
+	Reason     string
+	Actor      string
+	OnBehalfOf string
+	RoundID    int64
+	CreatedAt  time.Time
+}
+
+// recordScoreEvent logs a change to a score in game. It is done in the same
+// transaction as the change itself. Changes that leave the score where it
+// was are not logged.
+func recordScoreEvent(ctx context.Context, tx pgx.Tx, game int64, event ScoreEvent) error {
+	if event.Delta == 0 {
+		return nil
+	}
+	var roundID *int64
+	if event.RoundID != 0 {
+		roundID = &event.RoundID
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO score_events (game_id, table_name, guild_id, username, delta, score, reason, actor, on_behalf_of, round_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		game, event.TableName, event.GuildID, event.Username, event.Delta, event.Score, event.Reason, event.Actor, event.OnBehalfOf, roundID)
+	if err != nil {
+		return fmt.Errorf("there was an error recording the score change: %w", wrapPgError(err))
+	}
+	return nil
+}
+
+type ScoreHistory struct {
+	// Events is newest first.
+	Events []ScoreEvent
+	// Total is how many changes there are in the whole history.
+	Total int
+	Page  Page
+}
+
+func (h ScoreHistory) NextOffset() int {
+	return h.Page.nextOffset(len(h.Events), h.Total)
+}
+
+// ScoreHistory returns a page of the changes to username's score in
+// tableName for a guild, newest first.
+func (p *Postgres) ScoreHistory(tableName string, guildID string, username string, page Page, ctx context.Context) (ScoreHistory, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return ScoreHistory{}, err
+	}
+	if username == "" {
+		return ScoreHistory{}, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	page, err := page.validate()
+	if err != nil {
+		return ScoreHistory{}, err
+	}
+	game, err := gameID(ctx, p.pool, tableName)
+	if err != nil {
+		return ScoreHistory{}, err
+	}
+
+	history := ScoreHistory{Page: page}
+	err = p.pool.QueryRow(ctx, `
+		SELECT count(*) FROM score_events
+		WHERE game_id = $1 AND guild_id = $2 AND username = $3`,
+		game, guildID, username,
+	).Scan(&history.Total)
+	if err != nil {
+		return ScoreHistory{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, table_name, guild_id, username, delta, COALESCE(score, 0), reason, actor, on_behalf_of, COALESCE(round_id, 0), created_at
+		FROM score_events
+		WHERE game_id = $1 AND guild_id = $2 AND username = $3
+		ORDER BY id DESC
+		LIMIT $4 OFFSET $5`,
+		game, guildID, username, page.Limit, page.Offset)
+	if err != nil {
+		return ScoreHistory{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e ScoreEvent
+		err := rows.Scan(&e.ID, &e.TableName, &e.GuildID, &e.Username, &e.Delta, &e.Score, &e.Reason, &e.Actor, &e.OnBehalfOf, &e.RoundID, &e.CreatedAt)
+		if err != nil {
+			return ScoreHistory{}, fmt.Errorf("error scanning row: %w", err)
+		}
+		history.Events = append(history.Events, e)
+	}
+
+	if err = rows.Err(); err != nil {
+		return ScoreHistory{}, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return history, nil
+}
+
+// periodLeaderboard is Leaderboard for a period, summed up from the score
+// events since then rather than read from the running totals.
+func (p *Postgres) periodLeaderboard(game int64, guildID string, page LeaderboardPage, ctx context.Context) (Leaderboard, error) {
+	leaderboard := Leaderboard{Page: page}
+	err := p.pool.QueryRow(ctx, `
+		SELECT count(DISTINCT username) FROM score_events
+		WHERE game_id = $1 AND guild_id = $2 AND created_at >= $3`,
+		game, guildID, page.Since,
+	).Scan(&leaderboard.Total)
+	if err != nil {
+		return Leaderboard{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		WITH totals AS (
+			SELECT username, SUM(delta) AS score FROM score_events
+			WHERE game_id = $1 AND guild_id = $2 AND created_at >= $3
+			GROUP BY username
+		)
+		SELECT DENSE_RANK() OVER (ORDER BY score DESC), username, score
+		FROM totals
+		ORDER BY score DESC, username
+		LIMIT $4 OFFSET $5`,
+		game, guildID, page.Since, page.Limit, page.Offset)
+	if err != nil {
+		return Leaderboard{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LeaderboardEntry
+		err := rows.Scan(&entry.Rank, &entry.Username, &entry.Score)
+		if err != nil {
+			return Leaderboard{}, fmt.Errorf("error scanning row: %w", err)
+		}
+		leaderboard.Entries = append(leaderboard.Entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return Leaderboard{}, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return leaderboard, nil
+}
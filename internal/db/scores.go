@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ScoreOp is how ChangeScore applies its amount to a score.
+type ScoreOp string
+
+const (
+	ScoreIncrement ScoreOp = "increment"
+	ScoreDecrement ScoreOp = "decrement"
+	ScoreSet       ScoreOp = "set"
+)
+
+func ParseScoreOp(s string) (ScoreOp, error) {
+	switch op := ScoreOp(s); op {
+	case ScoreIncrement, ScoreDecrement, ScoreSet:
+		return op, nil
+	default:
+		return "", fmt.Errorf("%w: operation must be %s, %s or %s, got %q", ErrInvalidInput, ScoreIncrement, ScoreDecrement, ScoreSet, s)
+	}
+}
+
+// ScoreChange is a change to make to a score. Reason, Actor and OnBehalfOf
+// are kept in the score history, with Reason defaulting to the operation.
+// Actor is the client that made the change, and OnBehalfOf is who it says
+// it made it for.
+type ScoreChange struct {
+	Op         ScoreOp
+	Amount     int
+	Reason     string
+	Actor      string
+	OnBehalfOf string
+}
+
+func (c ScoreChange) validate(username string) (ScoreChange, error) {
+	if username == "" {
+		return ScoreChange{}, fmt.Errorf("%w: the user must not be empty", ErrInvalidInput)
+	}
+	if _, err := ParseScoreOp(string(c.Op)); err != nil {
+		return ScoreChange{}, err
+	}
+	if c.Amount < 0 {
+		return ScoreChange{}, fmt.Errorf("%w: the amount: %d must not be negative", ErrInvalidInput, c.Amount)
+	}
+	if c.Reason == "" {
+		c.Reason = string(c.Op)
+	}
+	return c, nil
+}
+
+// ChangeScore increments, decrements or sets username's score in tableName
+// for a guild in a single statement, adding the user first if they are not
+// there yet. It returns the new score.
+func (p *Postgres) ChangeScore(tableName string, guildID string, username string, change ScoreChange, ctx context.Context) (int, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return 0, err
+	}
+	change, err := change.validate(username)
+	if err != nil {
+		return 0, err
+	}
+	event := ScoreEvent{TableName: tableName, GuildID: guildID, Username: username, Reason: change.Reason, Actor: change.Actor, OnBehalfOf: change.OnBehalfOf}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	game, err := gameID(ctx, tx, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	var score int
+	switch change.Op {
+	case ScoreSet:
+		score, err = setScore(ctx, tx, game, change.Amount, event)
+	default:
+		delta := change.Amount
+		if change.Op == ScoreDecrement {
+			delta = -change.Amount
+		}
+		err = tx.QueryRow(ctx, `
+			INSERT INTO scores AS s (game_id, guild_id, username, score) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (game_id, guild_id, username) DO UPDATE SET score = s.score + EXCLUDED.score
+			RETURNING score`,
+			game, guildID, username, delta,
+		).Scan(&score)
+		if err != nil {
+			return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+		}
+		event.Delta = delta
+		event.Score = score
+		err = recordScoreEvent(ctx, tx, game, event)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+	}
+	return score, nil
+}
+
+// setScore overwrites username's score. Unlike an increment the change to
+// log depends on the old score, so concurrent sets for the same user are
+// serialized with a lock that also covers the user not existing yet.
+func setScore(ctx context.Context, tx pgx.Tx, game int64, score int, event ScoreEvent) (int, error) {
+	guildID, username := event.GuildID, event.Username
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, event.TableName+"/"+guildID+"/"+username)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+	}
+
+	var previous int
+	err = tx.QueryRow(ctx, `SELECT score FROM scores WHERE game_id = $1 AND guild_id = $2 AND username = $3`, game, guildID, username).Scan(&previous)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO scores (game_id, guild_id, username, score) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (game_id, guild_id, username) DO UPDATE SET score = EXCLUDED.score`,
+		game, guildID, username, score)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error updating the users score: %w", wrapPgError(err))
+	}
+
+	event.Delta = score - previous
+	event.Score = score
+	err = recordScoreEvent(ctx, tx, game, event)
+	if err != nil {
+		return 0, err
+	}
+	return score, nil
+}
@@ -0,0 +1,79 @@
+package db
+
+import "context"
+
+// Store is everything the HTTP API needs from the database. Postgres is the
+// real implementation and Memory is an in-process one for tests.
+//
+// Users, scores, answers and leaderboards are kept apart per Discord guild,
+// so the same table can be shared by every server the bot runs in. The empty
+// guild ID is the shared scope from before guilds were tracked.
+type Store interface {
+	TableStore
+	UserStore
+	ScoreStore
+	AnswerStore
+	LeaderboardStore
+	RoundStore
+}
+
+type TableStore interface {
+	ListTables(ctx context.Context) ([]string, error)
+	CreateTable(tableName string, ctx context.Context) (string, error)
+	DeleteTable(tableName string, ctx context.Context) (string, error)
+	DescribeTable(tableName string, ctx context.Context) (TableSummary, error)
+}
+
+type UserStore interface {
+	AddUserIfNotExist(tableName string, guildID string, username string, ctx context.Context) (string, error)
+	UpdateTableWithUser(tableName string, guildID string, username string, ctx context.Context) (string, error)
+	IdentifyUser(tableName string, guildID string, discordID string, username string, ctx context.Context) (User, error)
+	GetUser(discordID string, ctx context.Context) (User, error)
+	MergeUsers(tableName string, guildID string, from string, into string, ctx context.Context) (int, error)
+}
+
+type ScoreStore interface {
+	GetCurrentScore(tableName string, guildID string, username string, ctx context.Context) (int, error)
+	ChangeScore(tableName string, guildID string, username string, change ScoreChange, ctx context.Context) (int, error)
+	ScoreHistory(tableName string, guildID string, username string, page Page, ctx context.Context) (ScoreHistory, error)
+	ExportScores(tableName string, guildID string, fn func(ScoreRow) error, ctx context.Context) error
+	ImportScores(tableName string, guildID string, rows []ScoreRow, ctx context.Context) (ImportResult, error)
+}
+
+type AnswerStore interface {
+	PutAnswerInDB(tablenName string, guildID string, answer string, numberInArray int, ctx context.Context) (string, error)
+	ReadAnswerFromDB(tableName string, guildID string, column string, ctx context.Context) (string, error)
+}
+
+type LeaderboardStore interface {
+	GetLeaderboard(tableName string, guildID string, ctx context.Context) (string, error)
+	Leaderboard(tableName string, guildID string, page LeaderboardPage, ctx context.Context) (Leaderboard, error)
+	Standing(tableName string, guildID string, username string, window int, ctx context.Context) (Standing, error)
+}
+
+type RoundStore interface {
+	StartRound(ctx context.Context, round Round) (Round, error)
+	ActiveRound(ctx context.Context, guildID string, channelID string) (Round, error)
+	EndRound(ctx context.Context, roundID int64, status string) error
+	UseHint(ctx context.Context, roundID int64) (int, error)
+	SolveRound(ctx context.Context, roundID int64, username string, points int) (int, error)
+}
+
+// CatalogStore persists the Pokémon catalog between restarts. It is kept
+// apart from Store since only startup needs it.
+type CatalogStore interface {
+	LoadPokemon(ctx context.Context) ([]Pokemon, error)
+	SavePokemon(ctx context.Context, pokemon []Pokemon) error
+}
+
+var (
+	_ CatalogStore = (*Postgres)(nil)
+	_ CatalogStore = (*Memory)(nil)
+
+	_ Store = (*Postgres)(nil)
+	_ Store = (*Memory)(nil)
+
+	_ APIKeyStore = (*Postgres)(nil)
+	_ APIKeyStore = StaticAPIKeys(nil)
+	_ APIKeyStore = APIKeyStores(nil)
+)
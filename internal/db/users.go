@@ -0,0 +1,298 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// User is a Discord user, known by the ID Discord gives them rather than
+// their name, which they can change. Names is every name they have been
+// seen with, oldest first, and is only filled in by GetUser.
+type User struct {
+	ID        int64
+	DiscordID string
+	Name      string
+	Names     []UserName
+}
+
+type UserName struct {
+	Name   string
+	SeenAt time.Time
+}
+
+func validateUser(discordID string, username string) error {
+	if discordID == "" || username == "" {
+		return fmt.Errorf("%w: user_id: %s, or username: %s must not be empty", ErrInvalidInput, discordID, username)
+	}
+	return nil
+}
+
+// IdentifyUser records that discordID is now called username and makes sure
+// their row in a guild's tableName is under that name. A row from before
+// they were identified is taken over if it has their name, and a renamed
+// user's row and score history follow them to the new name. If the new name
+// already has a row of its own, which happens when a rename split a user in
+// two before they were identified, it is a conflict until the rows are
+// merged with MergeUsers.
+func (p *Postgres) IdentifyUser(tableName string, guildID string, discordID string, username string, ctx context.Context) (User, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return User{}, err
+	}
+	if err := validateUser(discordID, username); err != nil {
+		return User{}, err
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error identifying the user: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	game, err := gameID(ctx, tx, tableName)
+	if err != nil {
+		return User{}, err
+	}
+	user, err := upsertUser(ctx, tx, discordID, username)
+	if err != nil {
+		return User{}, err
+	}
+
+	var current string
+	err = tx.QueryRow(ctx, `
+		SELECT username FROM scores
+		WHERE game_id = $1 AND guild_id = $2 AND user_id = $3
+		FOR UPDATE`,
+		game, guildID, user.ID,
+	).Scan(&current)
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		err = claimRow(ctx, tx, game, guildID, user)
+	case err != nil:
+		err = fmt.Errorf("there was an error finding the user's score: %w", wrapPgError(err))
+	case current != username:
+		err = renameRow(ctx, tx, game, guildID, current, username)
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error identifying the user: %w", wrapPgError(err))
+	}
+	return user, nil
+}
+
+// upsertUser adds the user if they are new, and logs their name if it is new
+// or has changed.
+func upsertUser(ctx context.Context, tx pgx.Tx, discordID string, username string) (User, error) {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO users (discord_id, display_name) VALUES ($1, $2)
+		ON CONFLICT (discord_id) DO NOTHING`,
+		discordID, username)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error adding the user: %w", wrapPgError(err))
+	}
+	added := tag.RowsAffected() == 1
+
+	user := User{DiscordID: discordID}
+	err = tx.QueryRow(ctx, `SELECT id, display_name FROM users WHERE discord_id = $1 FOR UPDATE`, discordID).
+		Scan(&user.ID, &user.Name)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error finding the user: %w", wrapPgError(err))
+	}
+	if !added && user.Name == username {
+		return user, nil
+	}
+
+	if !added {
+		_, err = tx.Exec(ctx, `UPDATE users SET display_name = $2, updated_at = now() WHERE id = $1`, user.ID, username)
+		if err != nil {
+			return User{}, fmt.Errorf("there was an error renaming the user: %w", wrapPgError(err))
+		}
+		user.Name = username
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO user_names (user_id, display_name) VALUES ($1, $2)`, user.ID, username)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error recording the user's name: %w", wrapPgError(err))
+	}
+	return user, nil
+}
+
+// claimRow links the row under the user's name to them, adding it if there
+// is not one yet.
+func claimRow(ctx context.Context, tx pgx.Tx, game int64, guildID string, user User) error {
+	tag, err := tx.Exec(ctx, `
+		INSERT INTO scores AS s (game_id, guild_id, username, score, user_id) VALUES ($1, $2, $3, 0, $4)
+		ON CONFLICT (game_id, guild_id, username) DO UPDATE SET user_id = EXCLUDED.user_id
+		WHERE s.user_id IS NULL`,
+		game, guildID, user.Name, user.ID)
+	if err != nil {
+		return fmt.Errorf("there was an error adding the user's score: %w", wrapPgError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("the name %s belongs to another user: %w", user.Name, ErrConflict)
+	}
+	return nil
+}
+
+// renameRow moves a user's row and score history to their new name.
+func renameRow(ctx context.Context, tx pgx.Tx, game int64, guildID string, from string, to string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE scores SET username = $4
+		WHERE game_id = $1 AND guild_id = $2 AND username = $3`,
+		game, guildID, from, to)
+	if errors.Is(wrapPgError(err), ErrConflict) {
+		return fmt.Errorf("%s already has a score of their own, merge it into %s first: %w", to, from, ErrConflict)
+	}
+	if err != nil {
+		return fmt.Errorf("there was an error renaming the user's score: %w", wrapPgError(err))
+	}
+	return moveScoreEvents(ctx, tx, game, guildID, from, to)
+}
+
+func moveScoreEvents(ctx context.Context, tx pgx.Tx, game int64, guildID string, from string, to string) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE score_events SET username = $4
+		WHERE game_id = $1 AND guild_id = $2 AND username = $3`,
+		game, guildID, from, to)
+	if err != nil {
+		return fmt.Errorf("there was an error moving the score history: %w", wrapPgError(err))
+	}
+	return nil
+}
+
+// GetUser returns the user with discordID along with every name they have
+// been seen with.
+func (p *Postgres) GetUser(discordID string, ctx context.Context) (User, error) {
+	if discordID == "" {
+		return User{}, fmt.Errorf("%w: the user_id must not be empty", ErrInvalidInput)
+	}
+
+	user := User{DiscordID: discordID}
+	err := p.pool.QueryRow(ctx, `SELECT id, display_name FROM users WHERE discord_id = $1`, discordID).
+		Scan(&user.ID, &user.Name)
+	if err != nil {
+		return User{}, fmt.Errorf("there was an error finding the user: %w", wrapPgError(err))
+	}
+
+	rows, err := p.pool.Query(ctx, `
+		SELECT display_name, seen_at FROM user_names
+		WHERE user_id = $1
+		ORDER BY id`,
+		user.ID)
+	if err != nil {
+		return User{}, fmt.Errorf("error executing query: %w", wrapPgError(err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name UserName
+		if err := rows.Scan(&name.Name, &name.SeenAt); err != nil {
+			return User{}, fmt.Errorf("error scanning row: %w", err)
+		}
+		user.Names = append(user.Names, name)
+	}
+
+	if err = rows.Err(); err != nil {
+		return User{}, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+	return user, nil
+}
+
+// MergeUsers folds from's row in a guild's tableName into into's, adding the
+// scores together and moving from's score history across. It is for users
+// whose rename left them with two rows. Rows that belong to two different
+// Discord users are never merged. It returns the merged score.
+func (p *Postgres) MergeUsers(tableName string, guildID string, from string, into string, ctx context.Context) (int, error) {
+	if _, err := quoteIdentifier(tableName); err != nil {
+		return 0, err
+	}
+	if from == "" || into == "" || from == into {
+		return 0, fmt.Errorf("%w: from: %s, and into: %s must be two different users", ErrInvalidInput, from, into)
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error merging the users: %w", wrapPgError(err))
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	game, err := gameID(ctx, tx, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	type row struct {
+		score  int
+		userID *int64
+	}
+	rows := map[string]row{}
+	result, err := tx.Query(ctx, `
+		SELECT username, score, user_id FROM scores
+		WHERE game_id = $1 AND guild_id = $2 AND username = ANY($3)
+		FOR UPDATE`,
+		game, guildID, []string{from, into})
+	if err != nil {
+		return 0, fmt.Errorf("there was an error finding the users: %w", wrapPgError(err))
+	}
+	for result.Next() {
+		var username string
+		var r row
+		if err := result.Scan(&username, &r.score, &r.userID); err != nil {
+			result.Close()
+			return 0, fmt.Errorf("error scanning row: %w", err)
+		}
+		rows[username] = r
+	}
+	result.Close()
+	if err = result.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating through rows: %w", wrapPgError(err))
+	}
+
+	source, ok := rows[from]
+	if !ok {
+		return 0, fmt.Errorf("the user %s does not exist: %w", from, ErrNotFound)
+	}
+	target, ok := rows[into]
+	if !ok {
+		return 0, fmt.Errorf("the user %s does not exist: %w", into, ErrNotFound)
+	}
+	if source.userID != nil && target.userID != nil && *source.userID != *target.userID {
+		return 0, fmt.Errorf("%s and %s are different users: %w", from, into, ErrConflict)
+	}
+
+	// The source row goes first so its user_id can move without two rows
+	// holding it at once.
+	_, err = tx.Exec(ctx, `DELETE FROM scores WHERE game_id = $1 AND guild_id = $2 AND username = $3`, game, guildID, from)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error merging the users: %w", wrapPgError(err))
+	}
+	var score int
+	err = tx.QueryRow(ctx, `
+		UPDATE scores SET score = score + $4, user_id = COALESCE(user_id, $5)
+		WHERE game_id = $1 AND guild_id = $2 AND username = $3
+		RETURNING score`,
+		game, guildID, into, source.score, source.userID,
+	).Scan(&score)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error merging the users: %w", wrapPgError(err))
+	}
+	if err := moveScoreEvents(ctx, tx, game, guildID, from, into); err != nil {
+		return 0, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("there was an error merging the users: %w", wrapPgError(err))
+	}
+	return score, nil
+}
@@ -0,0 +1,353 @@
+package games
+
+import (
+	"context"
+	"embed"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/imlogang/api-service/internal/db"
+	"github.com/mtslzr/pokeapi-go"
+)
+
+//go:embed data/pokemon.json
+var bundledCatalog embed.FS
+
+// Catalog is where games get their Pokémon from.
+type Catalog interface {
+	// Random picks a Pokémon the filter allows, or returns
+	// ErrNoPokemonMatch.
+	Random(ctx context.Context, filter Filter) (Pokemon, error)
+	Lookup(ctx context.Context, number int) (Pokemon, error)
+}
+
+// MemoryCatalog serves a fixed set of Pokémon from memory.
+type MemoryCatalog struct {
+	pokemon  []Pokemon
+	byNumber map[int]Pokemon
+}
+
+func NewMemoryCatalog(pokemon []Pokemon) (*MemoryCatalog, error) {
+	if len(pokemon) == 0 {
+		return nil, errors.New("the pokemon catalog must not be empty")
+	}
+	c := &MemoryCatalog{byNumber: map[int]Pokemon{}}
+	for _, pm := range pokemon {
+		if pm.Number <= 0 || pm.Name == "" {
+			return nil, fmt.Errorf("the pokemon catalog entry %d %q needs a number and a name", pm.Number, pm.Name)
+		}
+		if pm.Generation == 0 {
+			pm.Generation = generationOf(pm.Number)
+		}
+		if _, ok := c.byNumber[pm.Number]; ok {
+			return nil, fmt.Errorf("the pokemon catalog has number %d more than once", pm.Number)
+		}
+		c.byNumber[pm.Number] = pm
+		c.pokemon = append(c.pokemon, pm)
+	}
+	sort.Slice(c.pokemon, func(i, j int) bool {
+		return c.pokemon[i].Number < c.pokemon[j].Number
+	})
+	return c, nil
+}
+
+func (c *MemoryCatalog) Random(_ context.Context, filter Filter) (Pokemon, error) {
+	if filter == (Filter{}) {
+		return c.pokemon[rand.Intn(len(c.pokemon))], nil
+	}
+
+	var allowed []Pokemon
+	for _, pm := range c.pokemon {
+		if filter.Allows(pm) {
+			allowed = append(allowed, pm)
+		}
+	}
+	if len(allowed) == 0 {
+		return Pokemon{}, ErrNoPokemonMatch
+	}
+	return allowed[rand.Intn(len(allowed))], nil
+}
+
+func (c *MemoryCatalog) Lookup(_ context.Context, number int) (Pokemon, error) {
+	pm, ok := c.byNumber[number]
+	if !ok {
+		return Pokemon{}, fmt.Errorf("there is no pokemon number %d in the catalog: %w", number, db.ErrNotFound)
+	}
+	return pm, nil
+}
+
+func (c *MemoryCatalog) Len() int {
+	return len(c.pokemon)
+}
+
+type CatalogOptions struct {
+	// SeedFile is a JSON or CSV file to seed the catalog from. The bundled
+	// Generation 1 seed is used when it is empty.
+	SeedFile string
+	// WarmFromPokeAPI fetches the whole National Pokédex from PokeAPI instead
+	// of reading a seed file.
+	WarmFromPokeAPI bool
+}
+
+// LoadCatalog returns the catalog saved in store. If nothing has been saved
+// yet it is seeded from a file or PokeAPI, as opts says, and saved so that
+// later starts do not need to do that again.
+func LoadCatalog(ctx context.Context, store db.CatalogStore, opts CatalogOptions) (catalog *MemoryCatalog, err error) {
+	ctx, span := o11y.StartSpan(ctx, "games: load catalog")
+	defer o11y.End(span, &err)
+
+	pokemon, err := store.LoadPokemon(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o11y.AddField(ctx, "stored", len(pokemon))
+
+	if len(pokemon) == 0 {
+		switch {
+		case opts.WarmFromPokeAPI:
+			o11y.AddField(ctx, "source", "pokeapi")
+			pokemon, err = warmFromPokeAPI(ctx, pokedexSize)
+		case opts.SeedFile != "":
+			o11y.AddField(ctx, "source", opts.SeedFile)
+			pokemon, err = readSeedFile(opts.SeedFile)
+		default:
+			o11y.AddField(ctx, "source", "bundled")
+			pokemon, err = readBundledSeed()
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		err = store.SavePokemon(ctx, pokemon)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	catalog, err = NewMemoryCatalog(pokemon)
+	if err != nil {
+		return nil, err
+	}
+	o11y.AddField(ctx, "pokemon", catalog.Len())
+	return catalog, nil
+}
+
+func readBundledSeed() ([]Pokemon, error) {
+	f, err := bundledCatalog.Open("data/pokemon.json")
+	if err != nil {
+		return nil, fmt.Errorf("there was an error opening the bundled catalog: %w", err)
+	}
+	defer f.Close()
+	return readSeed(f, ".json")
+}
+
+func readSeedFile(path string) ([]Pokemon, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("there was an error opening the catalog seed: %w", err)
+	}
+	defer f.Close()
+	return readSeed(f, filepath.Ext(path))
+}
+
+// readSeed parses a catalog seed. JSON seeds are an array of db.Pokemon. CSV
+// seeds have a header row and the columns
+// number,name,types,generation,legendary,base_experience where types are
+// separated by a "/", and everything after name may be left empty.
+func readSeed(r io.Reader, ext string) ([]Pokemon, error) {
+	switch strings.ToLower(ext) {
+	case ".json":
+		var pokemon []Pokemon
+		err := json.NewDecoder(r).Decode(&pokemon)
+		if err != nil {
+			return nil, fmt.Errorf("there was an error parsing the catalog seed: %w", err)
+		}
+		return pokemon, nil
+	case ".csv":
+		return readCSVSeed(r)
+	default:
+		return nil, fmt.Errorf("the catalog seed must be a .json or .csv file, got %q", ext)
+	}
+}
+
+func readCSVSeed(r io.Reader) ([]Pokemon, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("there was an error parsing the catalog seed: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	pokemon := make([]Pokemon, 0, len(records)-1)
+	for i, record := range records[1:] {
+		line := i + 2
+		field := func(n int) string {
+			if n < len(record) {
+				return strings.TrimSpace(record[n])
+			}
+			return ""
+		}
+		number, err := strconv.Atoi(field(0))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of the catalog seed has an invalid number: %w", line, err)
+		}
+		pm := Pokemon{Number: number, Name: field(1)}
+		if types := field(2); types != "" {
+			pm.Types = strings.Split(types, "/")
+		}
+		if generation := field(3); generation != "" {
+			pm.Generation, err = strconv.Atoi(generation)
+			if err != nil {
+				return nil, fmt.Errorf("line %d of the catalog seed has an invalid generation: %w", line, err)
+			}
+		} else {
+			pm.Generation = generationOf(number)
+		}
+		if legendary := field(4); legendary != "" {
+			pm.Legendary, err = strconv.ParseBool(legendary)
+			if err != nil {
+				return nil, fmt.Errorf("line %d of the catalog seed has an invalid legendary flag: %w", line, err)
+			}
+		} else {
+			pm.Legendary = isLegendary(number)
+		}
+		if baseExperience := field(5); baseExperience != "" {
+			pm.BaseExperience, err = strconv.Atoi(baseExperience)
+			if err != nil {
+				return nil, fmt.Errorf("line %d of the catalog seed has an invalid base experience: %w", line, err)
+			}
+		}
+		pokemon = append(pokemon, pm)
+	}
+	return pokemon, nil
+}
+
+// warmWorkers bounds how many requests are made to PokeAPI at once.
+const warmWorkers = 8
+
+// warmFromPokeAPI fetches Pokémon 1 to count from PokeAPI.
+func warmFromPokeAPI(ctx context.Context, count int) ([]Pokemon, error) {
+	pokemon := make([]Pokemon, count)
+	numbers := make(chan int)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+	for range warmWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for number := range numbers {
+				pm, err := fetchPokemon(number)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				pokemon[number-1] = pm
+			}
+		}()
+	}
+
+	for number := 1; number <= count; number++ {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		numbers <- number
+	}
+	close(numbers)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return pokemon, nil
+}
+
+func fetchPokemon(number int) (Pokemon, error) {
+	// The client caches every response, which adds up over the whole
+	// Pokédex, and nothing here reads from that cache.
+	defer pokeapi.ClearCache()
+
+	result, err := pokeapi.Pokemon(strconv.Itoa(number))
+	if err != nil {
+		return Pokemon{}, fmt.Errorf("there was an error getting Pokemon %d from PokeAPI: %w", number, err)
+	}
+
+	sort.Slice(result.Types, func(i, j int) bool {
+		return result.Types[i].Slot < result.Types[j].Slot
+	})
+	types := make([]string, 0, len(result.Types))
+	for _, t := range result.Types {
+		types = append(types, t.Type.Name)
+	}
+
+	return Pokemon{
+		Number:         number,
+		Name:           result.Name,
+		Types:          types,
+		Generation:     generationOf(number),
+		Legendary:      isLegendary(number),
+		BaseExperience: result.BaseExperience,
+	}, nil
+}
+
+// FileCatalogStore keeps the catalog in a JSON file on disk.
+type FileCatalogStore struct {
+	Path string
+}
+
+var _ db.CatalogStore = FileCatalogStore{}
+
+// LoadPokemon returns nothing, rather than an error, if the file does not
+// exist yet.
+func (s FileCatalogStore) LoadPokemon(_ context.Context) ([]Pokemon, error) {
+	f, err := os.Open(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("there was an error opening the catalog file: %w", err)
+	}
+	defer f.Close()
+	return readSeed(f, ".json")
+}
+
+// SavePokemon writes the catalog to a temporary file and renames it into
+// place so a reader never sees half a file.
+func (s FileCatalogStore) SavePokemon(_ context.Context, pokemon []Pokemon) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("there was an error creating the catalog file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = json.NewEncoder(tmp).Encode(pokemon)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("there was an error writing the catalog file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
@@ -0,0 +1,97 @@
+package games
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestLoadCatalog_Bundled(t *testing.T) {
+	ctx := testcontext.Background()
+	store := db.NewMemory()
+
+	catalog, err := LoadCatalog(ctx, store, CatalogOptions{})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(catalog.Len(), 151))
+
+	mewtwo, err := catalog.Lookup(ctx, 150)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(mewtwo.Name, "mewtwo"))
+	assert.Check(t, mewtwo.Legendary)
+
+	_, err = catalog.Lookup(ctx, 152)
+	assert.Check(t, errors.Is(err, db.ErrNotFound), "got: %v", err)
+
+	saved, err := store.LoadPokemon(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(saved, 151))
+}
+
+func TestLoadCatalog_StoredWinsOverSeed(t *testing.T) {
+	ctx := testcontext.Background()
+	store := FileCatalogStore{Path: filepath.Join(t.TempDir(), "catalog.json")}
+	err := store.SavePokemon(ctx, []Pokemon{{Number: 25, Name: "pikachu", Types: []string{"electric"}}})
+	assert.NilError(t, err)
+
+	catalog, err := LoadCatalog(ctx, store, CatalogOptions{SeedFile: "does-not-exist.csv"})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(catalog.Len(), 1))
+
+	pokemon, err := catalog.Random(ctx, Filter{})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(pokemon, Pokemon{Number: 25, Name: "pikachu", Types: []string{"electric"}, Generation: 1}))
+}
+
+func TestLoadCatalog_CSVSeed(t *testing.T) {
+	ctx := testcontext.Background()
+	dir := t.TempDir()
+	seed := filepath.Join(dir, "seed.csv")
+	err := os.WriteFile(seed, []byte(strings.Join([]string{
+		"number,name,types,generation,legendary,base_experience",
+		"1,bulbasaur,grass/poison,1,false,64",
+		"249,lugia,psychic/flying,,,",
+		"906,sprigatito",
+	}, "\n")), 0o600)
+	assert.NilError(t, err)
+	store := FileCatalogStore{Path: filepath.Join(dir, "catalog.json")}
+
+	catalog, err := LoadCatalog(ctx, store, CatalogOptions{SeedFile: seed})
+	assert.NilError(t, err)
+
+	tests := []struct {
+		number   int
+		expected Pokemon
+	}{
+		{number: 1, expected: Pokemon{Number: 1, Name: "bulbasaur", Types: []string{"grass", "poison"}, Generation: 1, BaseExperience: 64}},
+		{number: 249, expected: Pokemon{Number: 249, Name: "lugia", Types: []string{"psychic", "flying"}, Generation: 2, Legendary: true}},
+		{number: 906, expected: Pokemon{Number: 906, Name: "sprigatito", Generation: 9}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.expected.Name, func(t *testing.T) {
+			pokemon, err := catalog.Lookup(ctx, tt.number)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(pokemon, tt.expected))
+		})
+	}
+
+	saved, err := store.LoadPokemon(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(saved, 3))
+}
+
+func TestFileCatalogStore_Missing(t *testing.T) {
+	ctx := testcontext.Background()
+	store := FileCatalogStore{Path: filepath.Join(t.TempDir(), "catalog.json")}
+
+	pokemon, err := store.LoadPokemon(ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Len(pokemon, 0))
+}
@@ -0,0 +1,145 @@
+package games
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/imlogang/api-service/internal/db"
+)
+
+const (
+	LegendaryInclude = "include"
+	LegendaryExclude = "exclude"
+	LegendaryOnly    = "only"
+)
+
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// ErrNoPokemonMatch is returned when a filter rules out every Pokémon in the
+// catalog.
+var ErrNoPokemonMatch = fmt.Errorf("no pokemon in the catalog match the filter: %w", db.ErrNotFound)
+
+// Filter narrows down which Pokémon can be picked. The zero value allows
+// every Pokémon.
+type Filter struct {
+	// MinGeneration and MaxGeneration bound the generations allowed, with 0
+	// leaving that end open.
+	MinGeneration int
+	MaxGeneration int
+	// Legendary is one of LegendaryInclude, LegendaryExclude or
+	// LegendaryOnly, with empty meaning LegendaryInclude.
+	Legendary string
+	// Difficulty is one of DifficultyEasy, DifficultyMedium or
+	// DifficultyHard, with empty allowing all of them.
+	Difficulty string
+}
+
+// ParseFilter builds a Filter from the get_pokemon and game start request
+// fields. generation is a single generation like "1" or a range like "1-3".
+func ParseFilter(generation string, legendary string, difficulty string) (Filter, error) {
+	var f Filter
+	if generation != "" {
+		low, high, isRange := strings.Cut(generation, "-")
+		if !isRange {
+			high = low
+		}
+		var err error
+		f.MinGeneration, err = parseGeneration(low)
+		if err != nil {
+			return Filter{}, err
+		}
+		f.MaxGeneration, err = parseGeneration(high)
+		if err != nil {
+			return Filter{}, err
+		}
+		if f.MinGeneration > f.MaxGeneration {
+			return Filter{}, fmt.Errorf("%w: the generation range %s is backwards", db.ErrInvalidInput, generation)
+		}
+	}
+
+	switch legendary {
+	case "", LegendaryInclude, LegendaryExclude, LegendaryOnly:
+		f.Legendary = legendary
+	default:
+		return Filter{}, fmt.Errorf("%w: legendary must be %s, %s or %s, got %q", db.ErrInvalidInput, LegendaryInclude, LegendaryExclude, LegendaryOnly, legendary)
+	}
+
+	switch difficulty {
+	case "", DifficultyEasy, DifficultyMedium, DifficultyHard:
+		f.Difficulty = difficulty
+	default:
+		return Filter{}, fmt.Errorf("%w: difficulty must be %s, %s or %s, got %q", db.ErrInvalidInput, DifficultyEasy, DifficultyMedium, DifficultyHard, difficulty)
+	}
+	return f, nil
+}
+
+func parseGeneration(s string) (int, error) {
+	generation, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || generation < 1 || generation > len(generationStarts) {
+		return 0, fmt.Errorf("%w: the generation must be between 1 and %d, got %q", db.ErrInvalidInput, len(generationStarts), s)
+	}
+	return generation, nil
+}
+
+func (f Filter) Allows(pokemon Pokemon) bool {
+	if f.MinGeneration != 0 && pokemon.Generation < f.MinGeneration {
+		return false
+	}
+	if f.MaxGeneration != 0 && pokemon.Generation > f.MaxGeneration {
+		return false
+	}
+	switch f.Legendary {
+	case LegendaryExclude:
+		if pokemon.Legendary {
+			return false
+		}
+	case LegendaryOnly:
+		if !pokemon.Legendary {
+			return false
+		}
+	}
+	if f.Difficulty != "" && DifficultyOf(pokemon) != f.Difficulty {
+		return false
+	}
+	return true
+}
+
+// DifficultyOf rates how hard pokemon is to name. Players know the early
+// generations best, so that sets the starting tier. Where PokeAPI's base
+// experience is known it stands in for how often a Pokémon turns up: common
+// early-route Pokémon are a tier easier and rare, high-yield ones a tier
+// harder.
+func DifficultyOf(pokemon Pokemon) string {
+	tier := 1
+	switch {
+	case pokemon.Generation <= 1:
+		tier = 0
+	case pokemon.Generation >= 5:
+		tier = 2
+	}
+
+	switch {
+	case pokemon.BaseExperience == 0:
+	case pokemon.BaseExperience < 70:
+		tier--
+	case pokemon.BaseExperience >= 250:
+		tier++
+	}
+	if pokemon.Legendary {
+		tier++
+	}
+
+	switch {
+	case tier <= 0:
+		return DifficultyEasy
+	case tier == 1:
+		return DifficultyMedium
+	default:
+		return DifficultyHard
+	}
+}
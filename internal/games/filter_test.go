@@ -0,0 +1,90 @@
+package games
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		generation  string
+		legendary   string
+		difficulty  string
+		expected    Filter
+		expectedErr error
+	}{
+		{name: "Empty", expected: Filter{}},
+		{name: "Single generation", generation: "1", expected: Filter{MinGeneration: 1, MaxGeneration: 1}},
+		{name: "Generation range", generation: "2-4", expected: Filter{MinGeneration: 2, MaxGeneration: 4}},
+		{
+			name:       "Everything",
+			generation: "1",
+			legendary:  LegendaryExclude,
+			difficulty: DifficultyEasy,
+			expected:   Filter{MinGeneration: 1, MaxGeneration: 1, Legendary: LegendaryExclude, Difficulty: DifficultyEasy},
+		},
+		{name: "Backwards range", generation: "4-2", expectedErr: db.ErrInvalidInput},
+		{name: "Unknown generation", generation: "10", expectedErr: db.ErrInvalidInput},
+		{name: "Not a generation", generation: "kanto", expectedErr: db.ErrInvalidInput},
+		{name: "Unknown legendary", legendary: "sometimes", expectedErr: db.ErrInvalidInput},
+		{name: "Unknown difficulty", difficulty: "nightmare", expectedErr: db.ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := ParseFilter(tt.generation, tt.legendary, tt.difficulty)
+			if tt.expectedErr != nil {
+				assert.Check(t, errors.Is(err, tt.expectedErr), "got: %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(filter, tt.expected))
+		})
+	}
+}
+
+func TestMemoryCatalog_RandomFilter(t *testing.T) {
+	ctx := testcontext.Background()
+	catalog, err := NewMemoryCatalog([]Pokemon{
+		{Number: 16, Name: "pidgey", Generation: 1, BaseExperience: 50},
+		{Number: 150, Name: "mewtwo", Generation: 1, Legendary: true, BaseExperience: 340},
+		{Number: 197, Name: "umbreon", Generation: 2, BaseExperience: 184},
+		{Number: 906, Name: "sprigatito", Generation: 9, BaseExperience: 62},
+	})
+	assert.NilError(t, err)
+
+	tests := []struct {
+		name        string
+		filter      Filter
+		expected    []string
+		expectedErr error
+	}{
+		{name: "Gen 1 only", filter: Filter{MinGeneration: 1, MaxGeneration: 1}, expected: []string{"pidgey", "mewtwo"}},
+		{name: "No legendaries", filter: Filter{MaxGeneration: 1, Legendary: LegendaryExclude}, expected: []string{"pidgey"}},
+		{name: "Legendaries only", filter: Filter{Legendary: LegendaryOnly}, expected: []string{"mewtwo"}},
+		{name: "Easy", filter: Filter{Difficulty: DifficultyEasy}, expected: []string{"pidgey"}},
+		{name: "Medium", filter: Filter{Difficulty: DifficultyMedium}, expected: []string{"umbreon", "sprigatito"}},
+		{name: "Hard", filter: Filter{Difficulty: DifficultyHard}, expected: []string{"mewtwo"}},
+		{name: "Nothing matches", filter: Filter{MinGeneration: 3, MaxGeneration: 8}, expectedErr: ErrNoPokemonMatch},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			for range 20 {
+				pokemon, err := catalog.Random(ctx, tt.filter)
+				if tt.expectedErr != nil {
+					assert.Check(t, errors.Is(err, tt.expectedErr), "got: %v", err)
+					return
+				}
+				assert.NilError(t, err)
+				assert.Check(t, cmp.Contains(tt.expected, pokemon.Name))
+			}
+		})
+	}
+}
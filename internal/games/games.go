@@ -2,19 +2,57 @@ package games
 
 import (
 	"context"
-	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/circleci/ex/o11y"
-	"github.com/mtslzr/pokeapi-go"
+	"github.com/imlogang/api-service/internal/db"
 )
 
-func randomNumber() (number int) {
-	return rand.Intn(1025) + 1
+// pokedexSize is the number of Pokémon in the National Pokédex.
+const pokedexSize = 1025
+
+type Pokemon = db.Pokemon
+
+// generationStarts is the first National Pokédex number of each generation.
+var generationStarts = []int{1, 152, 252, 387, 494, 650, 722, 810, 906}
+
+func generationOf(number int) int {
+	generation := 0
+	for i, start := range generationStarts {
+		if number >= start {
+			generation = i + 1
+		}
+	}
+	return generation
+}
+
+// legendary is every legendary and mythical Pokémon. PokeAPI only has this on
+// the species resource, which the client does not expose, so it is kept here.
+var legendary = numberSet(
+	144, 145, 146, 150, 151,
+	243, 244, 245, 249, 250, 251,
+	377, 378, 379, 380, 381, 382, 383, 384, 385, 386,
+	480, 481, 482, 483, 484, 485, 486, 487, 488, 489, 490, 491, 492, 493,
+	494, 638, 639, 640, 641, 642, 643, 644, 645, 646, 647, 648, 649,
+	716, 717, 718, 719, 720, 721,
+	772, 773, 785, 786, 787, 788, 789, 790, 791, 792, 800, 801, 802, 807, 808, 809,
+	888, 889, 890, 891, 892, 893, 894, 895, 896, 897, 898, 905,
+	1001, 1002, 1003, 1004, 1007, 1008, 1014, 1015, 1016, 1017, 1024, 1025,
+)
+
+func numberSet(numbers ...int) map[int]bool {
+	set := make(map[int]bool, len(numbers))
+	for _, n := range numbers {
+		set[n] = true
+	}
+	return set
+}
+
+func isLegendary(number int) bool {
+	return legendary[number]
 }
 
-func GetPokemon(ctx context.Context) (string, error) {
+func GetPokemon(ctx context.Context, catalog Catalog, filter Filter) (string, error) {
 	var err error
 
 	ctx, getPokemon := o11y.StartSpan(ctx, "GetPokemon")
@@ -22,14 +60,13 @@ func GetPokemon(ctx context.Context) (string, error) {
 
 	o11y.AddFieldToTrace(ctx, "before-time", time.Now())
 
-	randomNumber := randomNumber()
-	pokemon, err := pokeapi.Resource("pokemon", randomNumber, 1025)
+	pokemon, err := catalog.Random(ctx, filter)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "pokemon-error", err)
-		return "", fmt.Errorf("there was an error getting a Pokemon: %s", err)
+		return "", err
 	}
 
 	o11y.AddFieldToTrace(ctx, "after-time", time.Now())
 
-	return pokemon.Results[0].Name, nil
+	return pokemon.Name, nil
 }
@@ -0,0 +1,117 @@
+package games
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/circleci/ex/o11y"
+)
+
+const (
+	HintType        = "type"
+	HintGeneration  = "generation"
+	HintFirstLetter = "first_letter"
+	HintLength      = "length"
+	HintNumberRange = "number_range"
+)
+
+// hintOrder is the order hints are given out in, vaguest first.
+var hintOrder = []string{HintType, HintGeneration, HintFirstLetter, HintLength, HintNumberRange}
+
+// numberRangeSize is how wide the Pokédex number range hint is.
+const numberRangeSize = 25
+
+type Hint struct {
+	Kind string
+	Text string
+}
+
+type HintResult struct {
+	// Hints is every hint given out so far this round, the newest last.
+	Hints []Hint
+	// Points is what solving the round is now worth.
+	Points int
+}
+
+// Hint gives out the next hint for the round running in the channel, along
+// with the ones already given. Once every hint is out asking again costs
+// nothing more.
+func (g *Game) Hint(ctx context.Context, guildID string, channelID string) (result HintResult, err error) {
+	ctx, span := o11y.StartSpan(ctx, "games: hint")
+	defer o11y.End(span, &err)
+	o11y.AddField(ctx, "guild_id", guildID)
+	o11y.AddField(ctx, "channel_id", channelID)
+
+	round, err := g.Round(ctx, guildID, channelID)
+	if err != nil {
+		return HintResult{}, err
+	}
+	o11y.AddField(ctx, "round_id", round.ID)
+
+	pokemon, err := g.cfg.Catalog.Lookup(ctx, round.PokedexNumber)
+	if err != nil {
+		return HintResult{}, err
+	}
+
+	hintsUsed := round.HintsUsed
+	if hintsUsed < len(hintOrder) {
+		hintsUsed, err = g.store.UseHint(ctx, round.ID)
+		if err != nil {
+			return HintResult{}, err
+		}
+	}
+	o11y.AddField(ctx, "hints_used", hintsUsed)
+
+	hints := make([]Hint, 0, len(hintOrder))
+	for _, kind := range hintOrder[:min(hintsUsed, len(hintOrder))] {
+		hints = append(hints, Hint{Kind: kind, Text: hintText(kind, pokemon)})
+	}
+	return HintResult{Hints: hints, Points: g.points(hintsUsed)}, nil
+}
+
+// points is what solving a round is worth after hintsUsed hints. A solve is
+// always worth at least a point.
+func (g *Game) points(hintsUsed int) int {
+	return max(g.cfg.Points-hintsUsed*g.cfg.HintPenalty, 1)
+}
+
+func hintText(kind string, pokemon Pokemon) string {
+	switch kind {
+	case HintType:
+		if len(pokemon.Types) == 0 {
+			return "Its type is a mystery."
+		}
+		return fmt.Sprintf("Its primary type is %s.", pokemon.Types[0])
+	case HintGeneration:
+		return fmt.Sprintf("It was introduced in Generation %d.", pokemon.Generation)
+	case HintFirstLetter:
+		first, _ := utf8.DecodeRuneInString(pokemon.Name)
+		return fmt.Sprintf("Its name starts with %s.", strings.ToUpper(string(first)))
+	case HintLength:
+		return fmt.Sprintf("Its name is %d characters long: %s", utf8.RuneCountInString(pokemon.Name), silhouette(pokemon.Name))
+	case HintNumberRange:
+		low := (pokemon.Number-1)/numberRangeSize*numberRangeSize + 1
+		return fmt.Sprintf("Its Pokédex number is between %d and %d.", low, low+numberRangeSize-1)
+	default:
+		return ""
+	}
+}
+
+// silhouette blanks out every letter of name after the first, leaving
+// punctuation such as the hyphen in "mr-mime" showing.
+func silhouette(name string) string {
+	var b strings.Builder
+	for i, r := range []rune(name) {
+		switch {
+		case i == 0:
+			b.WriteString(strings.ToUpper(string(r)))
+		case r == '-' || r == '.' || r == '\'' || r == ' ':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+package games
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestGame_Hint(t *testing.T) {
+	ctx := testcontext.Background()
+	catalog, err := NewMemoryCatalog([]Pokemon{
+		{Number: 122, Name: "mr-mime", Types: []string{"psychic", "fairy"}, Generation: 1},
+	})
+	assert.NilError(t, err)
+	clock := &testClock{now: time.Now()}
+	g := NewGame(db.NewMemory(), GameConfig{
+		RoundTimeout: time.Minute,
+		Points:       3,
+		Catalog:      catalog,
+		Now:          clock.Now,
+	})
+
+	_, err = g.Start(ctx, "guild", "channel", Filter{})
+	assert.NilError(t, err)
+
+	expected := []Hint{
+		{Kind: HintType, Text: "Its primary type is psychic."},
+		{Kind: HintGeneration, Text: "It was introduced in Generation 1."},
+		{Kind: HintFirstLetter, Text: "Its name starts with M."},
+		{Kind: HintLength, Text: "Its name is 7 characters long: M_-____"},
+		{Kind: HintNumberRange, Text: "Its Pokédex number is between 101 and 125."},
+	}
+	expectedPoints := []int{2, 1, 1, 1, 1}
+	for i := range expected {
+		result, err := g.Hint(ctx, "guild", "channel")
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(result, HintResult{Hints: expected[:i+1], Points: expectedPoints[i]}))
+	}
+
+	// Asking once every hint is out gives them all again.
+	result, err := g.Hint(ctx, "guild", "channel")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(result.Hints, expected))
+
+	guess, err := g.Guess(ctx, "guild", "channel", "test-user", "mr-mime")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(guess, GuessResult{Correct: true, Answer: "mr-mime", Points: 1, Score: 1}))
+
+	_, err = g.Hint(ctx, "guild", "channel")
+	assert.Check(t, errors.Is(err, ErrNoActiveRound), "got: %v", err)
+}
+
+func TestGame_HintReducesPoints(t *testing.T) {
+	ctx := testcontext.Background()
+	clock := &testClock{now: time.Now()}
+	g := newTestGame(db.NewMemory(), clock, "bulbasaur")
+
+	_, err := g.Start(ctx, "guild", "channel", Filter{})
+	assert.NilError(t, err)
+	result, err := g.Hint(ctx, "guild", "channel")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(result.Points, 2))
+
+	guess, err := g.Guess(ctx, "guild", "channel", "test-user", "bulbasaur")
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(guess.Points, 2))
+	assert.Check(t, cmp.Equal(guess.Score, 2))
+}
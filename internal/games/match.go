@@ -0,0 +1,107 @@
+package games
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match is how close a guess came to the answer.
+type Match int
+
+const (
+	MatchWrong Match = iota
+	MatchClose
+	MatchCorrect
+)
+
+// Matcher checks guesses against PokeAPI names, so "Mr. Mime" matches
+// "mr-mime" and "Farfetch'd" matches "farfetchd". Both are normalized first
+// and then compared by edit distance.
+type Matcher struct {
+	// Tolerance is how many edits a guess can be off by and still be
+	// correct.
+	Tolerance int
+	// CloseWithin is how many edits a wrong guess can be off by and still be
+	// reported as close. It has no effect at or below Tolerance.
+	CloseWithin int
+}
+
+func (m Matcher) Match(guess string, answer string) Match {
+	guess = normalizeName(guess)
+	answer = normalizeName(answer)
+	if guess == "" {
+		return MatchWrong
+	}
+	if guess == answer {
+		return MatchCorrect
+	}
+
+	distance := editDistance(guess, answer)
+	// Short names would otherwise match almost anything.
+	if distance*2 >= len([]rune(answer)) {
+		return MatchWrong
+	}
+	switch {
+	case distance <= m.Tolerance:
+		return MatchCorrect
+	case distance <= m.CloseWithin:
+		return MatchClose
+	default:
+		return MatchWrong
+	}
+}
+
+// nameReplacer spells out the symbols in names the way PokeAPI does, and
+// drops accents from the letters Pokémon names use.
+var nameReplacer = strings.NewReplacer(
+	"♀", "f",
+	"♂", "m",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"á", "a", "à", "a", "â", "a", "ä", "a",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+)
+
+// normalizeName lower cases name and removes everything but letters and
+// digits, so spaces, hyphens, dots and apostrophes don't count against a
+// guess.
+func normalizeName(name string) string {
+	name = nameReplacer.Replace(strings.ToLower(name))
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// editDistance is the optimal string alignment distance between a and b:
+// the number of insertions, deletions, substitutions and swaps of adjacent
+// letters it takes to turn one into the other.
+func editDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev2 := make([]int, len(br)+1)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(br)]
+}
@@ -0,0 +1,61 @@
+package games
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	closeWithinTwo := Matcher{CloseWithin: 2}
+	tests := []struct {
+		name     string
+		matcher  Matcher
+		guess    string
+		answer   string
+		expected Match
+	}{
+		{name: "Exact", matcher: closeWithinTwo, guess: "pikachu", answer: "pikachu", expected: MatchCorrect},
+		{name: "Case and spaces", matcher: closeWithinTwo, guess: "  PikaChu ", answer: "pikachu", expected: MatchCorrect},
+		{name: "Space for hyphen", matcher: closeWithinTwo, guess: "mr mime", answer: "mr-mime", expected: MatchCorrect},
+		{name: "Dot and space", matcher: closeWithinTwo, guess: "Mr. Mime", answer: "mr-mime", expected: MatchCorrect},
+		{name: "Gender suffix", matcher: closeWithinTwo, guess: "nidoran f", answer: "nidoran-f", expected: MatchCorrect},
+		{name: "Gender symbol", matcher: closeWithinTwo, guess: "Nidoran♀", answer: "nidoran-f", expected: MatchCorrect},
+		{name: "Apostrophe", matcher: closeWithinTwo, guess: "Farfetch'd", answer: "farfetchd", expected: MatchCorrect},
+		{name: "Accent", matcher: closeWithinTwo, guess: "Flabébé", answer: "flabebe", expected: MatchCorrect},
+		{name: "One letter off", matcher: closeWithinTwo, guess: "pikachoo", answer: "pikachu", expected: MatchClose},
+		{name: "Swapped letters", matcher: closeWithinTwo, guess: "pikahcu", answer: "pikachu", expected: MatchClose},
+		{name: "Two letters off", matcher: closeWithinTwo, guess: "bulbasuar", answer: "bulbasaur", expected: MatchClose},
+		{name: "Three letters off", matcher: closeWithinTwo, guess: "pikachooo", answer: "pikachu", expected: MatchWrong},
+		{name: "Different pokemon", matcher: closeWithinTwo, guess: "raichu", answer: "pikachu", expected: MatchWrong},
+		{name: "Short name", matcher: closeWithinTwo, guess: "mew", answer: "muk", expected: MatchWrong},
+		{name: "Empty guess", matcher: closeWithinTwo, guess: " - ", answer: "mr-mime", expected: MatchWrong},
+		{
+			name:     "Within tolerance",
+			matcher:  Matcher{Tolerance: 1, CloseWithin: 2},
+			guess:    "pikachoo",
+			answer:   "pikachu",
+			expected: MatchClose,
+		},
+		{
+			name:     "Within tolerance by one edit",
+			matcher:  Matcher{Tolerance: 1, CloseWithin: 2},
+			guess:    "pikachuu",
+			answer:   "pikachu",
+			expected: MatchCorrect,
+		},
+		{
+			name:     "Close turned off",
+			guess:    "pikachuu",
+			answer:   "pikachu",
+			expected: MatchWrong,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Check(t, cmp.Equal(tt.matcher.Match(tt.guess, tt.answer), tt.expected))
+		})
+	}
+}
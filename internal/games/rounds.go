@@ -0,0 +1,157 @@
+package games
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+var (
+	ErrNoActiveRound = fmt.Errorf("there is no active round in this channel: %w", db.ErrNotFound)
+	ErrRoundExpired  = fmt.Errorf("the round has expired: %w", db.ErrNotFound)
+)
+
+type GameConfig struct {
+	// RoundTimeout is how long a round runs before it expires unsolved.
+	RoundTimeout time.Duration
+	// Points is what a correct guess is worth before any hints.
+	Points int
+	// HintPenalty is taken off Points for each hint used, down to a
+	// minimum of one point.
+	HintPenalty int
+
+	// Matcher decides whether a guess is right or close. The zero value
+	// only accepts exact matches and never reports a guess as close.
+	Matcher Matcher
+
+	// Catalog is where the Pokémon for each round is picked from.
+	Catalog Catalog
+	// Now is the clock rounds are timed against, defaulting to time.Now.
+	Now func() time.Time
+}
+
+// Game runs Pokémon guessing rounds, one per Discord channel. The answer
+// stays in the store and guesses are checked here rather than by the bot.
+type Game struct {
+	store db.RoundStore
+	cfg   GameConfig
+}
+
+func NewGame(store db.RoundStore, cfg GameConfig) *Game {
+	if cfg.RoundTimeout <= 0 {
+		cfg.RoundTimeout = 5 * time.Minute
+	}
+	if cfg.Points <= 0 {
+		cfg.Points = 1
+	}
+	if cfg.HintPenalty <= 0 {
+		cfg.HintPenalty = 1
+	}
+	if cfg.Now == nil {
+		cfg.Now = time.Now
+	}
+	return &Game{store: store, cfg: cfg}
+}
+
+type GuessResult struct {
+	Correct bool
+	// Close is set for a wrong guess that was only a letter or two off.
+	Close bool
+	// Answer, Points and Score are only set for a correct guess.
+	Answer string
+	Points int
+	Score  int
+}
+
+// Start begins a new round in the channel with a Pokémon the filter allows,
+// replacing any round already running there.
+func (g *Game) Start(ctx context.Context, guildID string, channelID string, filter Filter) (round db.Round, err error) {
+	ctx, span := o11y.StartSpan(ctx, "games: start round")
+	defer o11y.End(span, &err)
+	o11y.AddField(ctx, "guild_id", guildID)
+	o11y.AddField(ctx, "channel_id", channelID)
+
+	pokemon, err := g.cfg.Catalog.Random(ctx, filter)
+	if err != nil {
+		return db.Round{}, err
+	}
+
+	round, err = g.store.StartRound(ctx, db.Round{
+		GuildID:       guildID,
+		ChannelID:     channelID,
+		Answer:        pokemon.Name,
+		PokedexNumber: pokemon.Number,
+		ExpiresAt:     g.cfg.Now().Add(g.cfg.RoundTimeout),
+	})
+	if err != nil {
+		return db.Round{}, err
+	}
+	o11y.AddField(ctx, "round_id", round.ID)
+	return round, nil
+}
+
+// Round returns the round running in the channel. A round that has run past
+// its timeout is ended as expired and ErrRoundExpired is returned.
+func (g *Game) Round(ctx context.Context, guildID string, channelID string) (db.Round, error) {
+	round, err := g.store.ActiveRound(ctx, guildID, channelID)
+	if errors.Is(err, db.ErrNotFound) {
+		return db.Round{}, ErrNoActiveRound
+	}
+	if err != nil {
+		return db.Round{}, err
+	}
+
+	if !g.cfg.Now().Before(round.ExpiresAt) {
+		err := g.store.EndRound(ctx, round.ID, db.RoundExpired)
+		if err != nil && !errors.Is(err, db.ErrConflict) {
+			return db.Round{}, err
+		}
+		return db.Round{}, ErrRoundExpired
+	}
+	return round, nil
+}
+
+// Guess checks username's guess against the round running in the channel and
+// awards the points if it is right.
+func (g *Game) Guess(ctx context.Context, guildID string, channelID string, username string, guess string) (result GuessResult, err error) {
+	ctx, span := o11y.StartSpan(ctx, "games: guess")
+	defer o11y.End(span, &err)
+	o11y.AddField(ctx, "guild_id", guildID)
+	o11y.AddField(ctx, "channel_id", channelID)
+	o11y.AddField(ctx, "username", username)
+
+	if username == "" || guess == "" {
+		return GuessResult{}, fmt.Errorf("%w: username: %s, or guess must not be empty", db.ErrInvalidInput, username)
+	}
+
+	round, err := g.Round(ctx, guildID, channelID)
+	if err != nil {
+		return GuessResult{}, err
+	}
+	o11y.AddField(ctx, "round_id", round.ID)
+
+	match := g.cfg.Matcher.Match(guess, round.Answer)
+	if match != MatchCorrect {
+		o11y.AddField(ctx, "correct", false)
+		o11y.AddField(ctx, "close", match == MatchClose)
+		return GuessResult{Correct: false, Close: match == MatchClose}, nil
+	}
+
+	points := g.points(round.HintsUsed)
+	score, err := g.store.SolveRound(ctx, round.ID, username, points)
+	if err != nil {
+		return GuessResult{}, err
+	}
+	o11y.AddField(ctx, "correct", true)
+	o11y.AddField(ctx, "hints_used", round.HintsUsed)
+	return GuessResult{
+		Correct: true,
+		Answer:  round.Answer,
+		Points:  points,
+		Score:   score,
+	}, nil
+}
@@ -0,0 +1,139 @@
+package games
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+// sequenceCatalog hands out names in order so each round's answer is known.
+type sequenceCatalog struct {
+	names  []string
+	picked int
+}
+
+func (c *sequenceCatalog) Random(_ context.Context, _ Filter) (Pokemon, error) {
+	name := c.names[c.picked%len(c.names)]
+	c.picked++
+	return Pokemon{Number: c.picked, Name: name}, nil
+}
+
+func (c *sequenceCatalog) Lookup(_ context.Context, number int) (Pokemon, error) {
+	return Pokemon{Number: number, Name: c.names[(number-1)%len(c.names)]}, nil
+}
+
+func newTestGame(store db.RoundStore, clock *testClock, names ...string) *Game {
+	return NewGame(store, GameConfig{
+		RoundTimeout: time.Minute,
+		Points:       3,
+		Now:          clock.Now,
+		Catalog:      &sequenceCatalog{names: names},
+	})
+}
+
+func TestGame_Guess(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name           string
+		guess          string
+		advance        time.Duration
+		expectedResult GuessResult
+		expectedErr    error
+	}{
+		{
+			name:           "Correct guess",
+			guess:          "Bulbasaur",
+			expectedResult: GuessResult{Correct: true, Answer: "bulbasaur", Points: 3, Score: 3},
+		},
+		{
+			name:           "Wrong guess",
+			guess:          "ivysaur",
+			expectedResult: GuessResult{Correct: false},
+		},
+		{
+			// The test game has the zero Matcher, which never calls a
+			// guess close.
+			name:           "Nearly right guess",
+			guess:          "bulbasaurr",
+			expectedResult: GuessResult{Correct: false},
+		},
+		{
+			name:        "Correct guess after the round expired",
+			guess:       "bulbasaur",
+			advance:     time.Minute,
+			expectedErr: ErrRoundExpired,
+		},
+		{
+			name:        "Empty guess",
+			guess:       "",
+			expectedErr: db.ErrInvalidInput,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+			store := db.NewMemory()
+			g := newTestGame(store, clock, "bulbasaur")
+
+			_, err := g.Start(ctx, "guild", "channel", Filter{})
+			assert.NilError(t, err)
+
+			clock.now = clock.now.Add(tt.advance)
+			result, err := g.Guess(ctx, "guild", "channel", "test-user", tt.guess)
+			if tt.expectedErr != nil {
+				assert.Check(t, errors.Is(err, tt.expectedErr), "got: %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(result, tt.expectedResult))
+		})
+	}
+}
+
+func TestGame_RoundsArePerChannel(t *testing.T) {
+	ctx := testcontext.Background()
+	clock := &testClock{now: time.Now()}
+	g := newTestGame(db.NewMemory(), clock, "bulbasaur", "charmander", "squirtle")
+
+	_, err := g.Start(ctx, "guild", "channel-1", Filter{})
+	assert.NilError(t, err)
+	_, err = g.Start(ctx, "guild", "channel-2", Filter{})
+	assert.NilError(t, err)
+
+	result, err := g.Guess(ctx, "guild", "channel-2", "test-user", "charmander")
+	assert.NilError(t, err)
+	assert.Check(t, result.Correct)
+
+	// Solving channel-2 leaves channel-1 running.
+	result, err = g.Guess(ctx, "guild", "channel-1", "test-user", "bulbasaur")
+	assert.NilError(t, err)
+	assert.Check(t, result.Correct)
+	assert.Check(t, cmp.Equal(result.Score, 6))
+
+	// A new round replaces the one already running in a channel.
+	_, err = g.Start(ctx, "guild", "channel-1", Filter{})
+	assert.NilError(t, err)
+	_, err = g.Start(ctx, "guild", "channel-1", Filter{})
+	assert.NilError(t, err)
+	result, err = g.Guess(ctx, "guild", "channel-1", "test-user", "squirtle")
+	assert.NilError(t, err)
+	assert.Check(t, !result.Correct)
+
+	_, err = g.Guess(ctx, "guild", "channel-3", "test-user", "bulbasaur")
+	assert.Check(t, errors.Is(err, ErrNoActiveRound), "got: %v", err)
+}
@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// AdminOptions guards the routes that create and archive tables.
+type AdminOptions struct {
+	// Token must be sent as a bearer token to use the admin routes. They are
+	// turned off when it is empty.
+	Token string
+	// Tables are the tables the admin routes can manage. A name ending in *
+	// matches every table starting with the rest of it.
+	Tables []string
+}
+
+// manages reports whether tableName is one of the tables the admin routes
+// can manage.
+func (o AdminOptions) manages(tableName string) bool {
+	for _, allowed := range o.Tables {
+		if prefix, ok := strings.CutSuffix(allowed, "*"); ok {
+			if strings.HasPrefix(tableName, prefix) {
+				return true
+			}
+			continue
+		}
+		if tableName == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAdmin stops requests that do not carry the admin token.
+func (a *API) requireAdmin(c *gin.Context) {
+	if a.admin.Token == "" {
+		writeForbidden(c, "the admin routes are turned off")
+		return
+	}
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.admin.Token)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="admin"`)
+		writeUnauthorized(c, "an admin token is required")
+		return
+	}
+	c.Next()
+}
+
+// checkManaged writes a forbidden response and returns false if tableName is
+// not one the admin routes can manage.
+func (a *API) checkManaged(c *gin.Context, tableName string) bool {
+	if a.admin.manages(tableName) {
+		return true
+	}
+	writeForbidden(c, fmt.Sprintf("the table %s can not be managed through the api", tableName))
+	return false
+}
+
+// Actions reported by a dry run.
+const (
+	actionCreate  = "create"
+	actionArchive = "archive"
+	actionNone    = "none"
+)
+
+// dryRunBody is what creating or archiving a table would do.
+type dryRunBody struct {
+	DryRun  bool   `json:"dry_run"`
+	Action  string `json:"action"`
+	Table   string `json:"table"`
+	Exists  bool   `json:"exists"`
+	Scores  int    `json:"scores"`
+	Answers int    `json:"answers"`
+	Events  int    `json:"events"`
+}
+
+func newDryRunBody(action string, summary db.TableSummary) dryRunBody {
+	return dryRunBody{
+		DryRun:  true,
+		Action:  action,
+		Table:   summary.Name,
+		Exists:  summary.Exists,
+		Scores:  summary.Scores,
+		Answers: summary.Answers,
+		Events:  summary.Events,
+	}
+}
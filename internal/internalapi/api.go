@@ -7,6 +7,7 @@ import (
 	"github.com/imlogang/api-service/internal/db"
 	"github.com/imlogang/api-service/internal/games"
 	"net/http"
+	"time"
 )
 
 type requestBody struct {
@@ -17,6 +18,28 @@ type requestBody struct {
 	SecondColumn string `json:"second_column"`
 	NumInArray   int    `json:"numinarray"`
 	Answer       string `json:"answer"`
+	GuildID      string `json:"guild_id"`
+	ChannelID    string `json:"channel_id"`
+	Guess        string `json:"guess"`
+	Generation   string `json:"generation"`
+	Legendary    string `json:"legendary"`
+	Difficulty   string `json:"difficulty"`
+	Operation    string `json:"operation"`
+	Reason       string `json:"reason"`
+	OnBehalfOf   string `json:"on_behalf_of"`
+	// Actor is the old name for OnBehalfOf.
+	Actor  string `json:"actor"`
+	UserID string `json:"user_id"`
+	From   string `json:"from"`
+	Into   string `json:"into"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type scoreBody struct {
+	UpdateAnswer string     `json:"update_answer"`
+	Username     string     `json:"username"`
+	Operation    db.ScoreOp `json:"operation"`
+	Score        int        `json:"score"`
 }
 
 type returnBody struct {
@@ -25,7 +48,6 @@ type returnBody struct {
 	TableCreated string   `json:"table_created,omitempty"`
 	TableDeleted string   `json:"table_deleted,omitempty"`
 	UpdateAnswer string   `json:"update_answer,omitempty"`
-	Error        string   `json:"error,omitempty"`
 	AddedUser    string   `json:"added_user,omitempty"`
 }
 
@@ -36,9 +58,9 @@ func (a *API) HelloWorldHandler(c *gin.Context) {
 func (a *API) ListTablesHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	tables, err := db.ListTables(ctx)
+	tables, err := a.store.ListTables(ctx)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -48,19 +70,38 @@ func (a *API) ListTablesHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, returnBody{Tables: tables})
 }
 
+// CreateTableHandler adds a table, or with dry_run set reports whether it
+// would. Only admins can use it, and only on the tables they can manage.
 func (a *API) CreateTableHandler(c *gin.Context) {
 	var requestBody requestBody
 	ctx := c.Request.Context()
 
 	err := c.BindJSON(&requestBody)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, returnBody{Error: err.Error()})
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if !a.checkManaged(c, requestBody.TableName) {
+		return
+	}
+	if requestBody.DryRun {
+		summary, err := a.store.DescribeTable(requestBody.TableName, ctx)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		action := actionCreate
+		if summary.Exists {
+			action = actionNone
+		}
+		c.JSON(http.StatusOK, newDryRunBody(action, summary))
 		return
 	}
 
-	sql, err := db.CreateTable(requestBody.TableName, ctx)
+	sql, err := a.store.CreateTable(requestBody.TableName, ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -73,18 +114,37 @@ func (a *API) CreateTableHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, returnBody{TableCreated: requestBody.TableName})
 }
 
+// DeleteTableHandler archives a table, or with dry_run set reports what
+// would be archived. Like CreateTableHandler it is only for admins.
 func (a *API) DeleteTableHandler(c *gin.Context) {
 	var requestBody requestBody
 	ctx := c.Request.Context()
 	err := c.BindJSON(&requestBody)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, returnBody{Error: err.Error()})
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	if !a.checkManaged(c, requestBody.TableName) {
+		return
+	}
+	if requestBody.DryRun {
+		summary, err := a.store.DescribeTable(requestBody.TableName, ctx)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if !summary.Exists {
+			writeError(c, fmt.Errorf("the table %s does not exist: %w", requestBody.TableName, db.ErrNotFound))
+			return
+		}
+		c.JSON(http.StatusOK, newDryRunBody(actionArchive, summary))
 		return
 	}
 
-	sql, err := db.DeleteTable(requestBody.TableName, ctx)
+	sql, err := a.store.DeleteTable(requestBody.TableName, ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -106,14 +166,20 @@ func (a *API) UpdateTableWithUserHandler(c *gin.Context) {
 	defer o11y.End(updateTableWithUserSpan, &err)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "update-table-with-user", requestBody)
-		c.JSON(http.StatusBadRequest, returnBody{Error: err.Error()})
+		writeBadRequest(c, err.Error())
 		return
 	}
 
-	_, err = db.UpdateTableWithUser(requestBody.TableName, requestBody.User, ctx)
+	// An identified user is added if they are new, and otherwise left as
+	// they are.
+	if requestBody.UserID != "" {
+		_, err = a.store.IdentifyUser(requestBody.TableName, requestBody.GuildID, requestBody.UserID, requestBody.User, ctx)
+	} else {
+		_, err = a.store.UpdateTableWithUser(requestBody.TableName, requestBody.GuildID, requestBody.User, ctx)
+	}
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 	c.Header("Content-Type", "application/json")
@@ -124,6 +190,7 @@ func (a *API) GetScoreHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 
 	tableName := c.Query("tablename")
+	guildID := c.Query("guild_id")
 	username := c.Query("username")
 
 	var err error
@@ -132,14 +199,21 @@ func (a *API) GetScoreHandler(c *gin.Context) {
 	if tableName == "" || username == "" {
 		o11y.AddFieldToTrace(ctx, "table_name", tableName)
 		o11y.AddFieldToTrace(ctx, "username", username)
-		c.JSON(http.StatusBadRequest, returnBody{Error: "tablename or username required"})
+		writeBadRequest(c, "tablename or username required")
 		return
 	}
 
-	score, err := db.GetCurrentScore(tableName, username, ctx)
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	err = a.identifyUser(ctx, tableName, guildID, c.Query("user_id"), username)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	score, err := a.store.GetCurrentScore(tableName, guildID, username, ctx)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -147,6 +221,9 @@ func (a *API) GetScoreHandler(c *gin.Context) {
 	c.String(http.StatusOK, "Score for %s: %d\n", username, score)
 }
 
+// UpdateScoreForUserHandler increments, decrements or sets a user's score,
+// adding them if they are new, and returns the new score. Without an
+// operation the score is set, as it always was.
 func (a *API) UpdateScoreForUserHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 	var requestBody requestBody
@@ -156,18 +233,54 @@ func (a *API) UpdateScoreForUserHandler(c *gin.Context) {
 
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "update-score-for-user", requestBody)
-		c.JSON(http.StatusBadRequest, returnBody{Error: err.Error()})
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if requestBody.Column != "" && requestBody.Column != "score" {
+		writeBadRequest(c, fmt.Sprintf("only the score column can be updated, got %q", requestBody.Column))
 		return
 	}
 
-	sql, err := db.UpdateScoreForUser(requestBody.TableName, requestBody.User, requestBody.Score, requestBody.Column, ctx)
+	op := db.ScoreSet
+	if requestBody.Operation != "" {
+		op, err = db.ParseScoreOp(requestBody.Operation)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+	}
+	o11y.AddFieldToTrace(ctx, "operation", op)
+	o11y.AddFieldToTrace(ctx, "guild-id", requestBody.GuildID)
+
+	err = a.identifyUser(ctx, requestBody.TableName, requestBody.GuildID, requestBody.UserID, requestBody.User)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
-	c.Header("Content-Type", "application/json")
-	c.JSON(http.StatusOK, returnBody{UpdateAnswer: sql})
+	onBehalfOf := requestBody.OnBehalfOf
+	if onBehalfOf == "" {
+		onBehalfOf = requestBody.Actor
+	}
+	apiKey, _ := ClientFromContext(ctx)
+	score, err := a.store.ChangeScore(requestBody.TableName, requestBody.GuildID, requestBody.User, db.ScoreChange{
+		Op:         op,
+		Amount:     requestBody.Score,
+		Reason:     requestBody.Reason,
+		Actor:      apiKey.Client,
+		OnBehalfOf: onBehalfOf,
+	}, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, scoreBody{
+		UpdateAnswer: "the score for the user has been updated",
+		Username:     requestBody.User,
+		Operation:    op,
+		Score:        score,
+	})
 }
 
 func (a *API) GetPokemonHandler(c *gin.Context) {
@@ -177,10 +290,17 @@ func (a *API) GetPokemonHandler(c *gin.Context) {
 	ctx, getPokemonHandlerSpan := o11y.StartSpan(ctx, "GetPokemonHandler")
 	defer o11y.End(getPokemonHandlerSpan, &err)
 
-	pokemon, err := games.GetPokemon(ctx)
+	filter, err := games.ParseFilter(c.Query("generation"), c.Query("legendary"), c.Query("difficulty"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "filter", filter)
+
+	pokemon, err := games.GetPokemon(ctx, a.catalog, filter)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 
@@ -192,6 +312,7 @@ func (a *API) GetPokemonHandler(c *gin.Context) {
 func (a *API) ReadAnswerFromDBHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 	tableName := c.Query("tablename")
+	guildID := c.Query("guild_id")
 	column := c.Query("colum")
 
 	ctx, answerFromTableSpan := o11y.StartSpan(ctx, "ReadAnswerFromDBHandler")
@@ -201,13 +322,14 @@ func (a *API) ReadAnswerFromDBHandler(c *gin.Context) {
 	if tableName == "" || column == "" {
 		o11y.AddFieldToTrace(ctx, "table-name", tableName)
 		o11y.AddFieldToTrace(ctx, "colum-name", column)
-		c.JSON(http.StatusBadRequest, returnBody{Error: "tablename or column required"})
+		writeBadRequest(c, "tablename or column required")
 		return
 	}
-	answer, err := db.ReadAnswerFromDB(tableName, column, ctx)
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	answer, err := a.store.ReadAnswerFromDB(tableName, guildID, column, ctx)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
 	o11y.AddFieldToTrace(ctx, "answer", answer)
@@ -216,9 +338,12 @@ func (a *API) ReadAnswerFromDBHandler(c *gin.Context) {
 	c.String(http.StatusOK, answer)
 }
 
+// LeaderboardHandler returns a page of a guild's leaderboard as JSON, or in
+// the original plain text format for clients that ask for text/plain.
 func (a *API) LeaderboardHandler(c *gin.Context) {
 	ctx := c.Request.Context()
 	tableName := c.Query("tablename")
+	guildID := c.Query("guild_id")
 
 	var err error
 	ctx, leaderboardHandlerSpan := o11y.StartSpan(ctx, "LeaderboardHandler")
@@ -226,16 +351,29 @@ func (a *API) LeaderboardHandler(c *gin.Context) {
 
 	if tableName == "" {
 		o11y.AddFieldToTrace(ctx, "table-name", tableName)
-		c.JSON(http.StatusBadRequest, returnBody{Error: "tablename required"})
+		writeBadRequest(c, "tablename required")
+		return
+	}
+
+	period, page, err := leaderboardPage(c, time.Now())
+	if err != nil {
+		writeBadRequest(c, err.Error())
 		return
 	}
+	o11y.AddFieldToTrace(ctx, "period", period)
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
 
-	leaderboard, err := db.GetLeaderboard(tableName, ctx)
+	leaderboard, err := a.store.Leaderboard(tableName, guildID, page, ctx)
 	if err != nil {
 		o11y.AddFieldToTrace(ctx, "db-error", err)
-		c.JSON(http.StatusInternalServerError, returnBody{Error: err.Error()})
+		writeError(c, err)
 		return
 	}
-	c.Header("Content-Type", "text/plain")
-	c.String(http.StatusOK, fmt.Sprintf("\n%s", leaderboard))
+
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.Header("Content-Type", "text/plain")
+		c.String(http.StatusOK, fmt.Sprintf("\n%s", leaderboard))
+		return
+	}
+	c.JSON(http.StatusOK, newLeaderboardBody(tableName, guildID, period, leaderboard))
 }
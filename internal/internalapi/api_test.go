@@ -3,15 +3,91 @@ package httpapi
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/circleci/ex/testing/testcontext"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+	"github.com/imlogang/api-service/internal/games"
 	"gotest.tools/v3/assert"
 	"gotest.tools/v3/assert/cmp"
+	"io"
+	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
+// newTestStore returns an in-memory store seeded with the given users, all on
+// the pokemon_scores table.
+func newTestStore(t *testing.T, scores map[string]int) *db.Memory {
+	return newGuildTestStore(t, "", scores)
+}
+
+// newGuildTestStore is newTestStore with the scores in guildID.
+func newGuildTestStore(t *testing.T, guildID string, scores map[string]int) *db.Memory {
+	ctx := testcontext.Background()
+	store := db.NewMemory()
+	for _, username := range sortedKeys(scores) {
+		_, err := store.UpdateTableWithUser("pokemon_scores", guildID, username, ctx)
+		assert.NilError(t, err)
+		if scores[username] != 0 {
+			_, err = store.ChangeScore("pokemon_scores", guildID, username, db.ScoreChange{Op: db.ScoreSet, Amount: scores[username]}, ctx)
+			assert.NilError(t, err)
+		}
+	}
+	return store
+}
+
+// testAdminToken is the admin token the test API is set up with.
+const testAdminToken = "test-admin-token"
+
+// testAPIKey is the API key the test API accepts, for the test-bot client.
+const testAPIKey = "test-api-key"
+
+// newTestRequest is httptest.NewRequest with the test API key set.
+func newTestRequest(method string, target string, body io.Reader) *http.Request {
+	req := httptest.NewRequest(method, target, body)
+	req.Header.Set(apiKeyHeader, testAPIKey)
+	return req
+}
+
+// testOptions wires the API to store, with a catalog of only Pikachu so
+// every round and get_pokemon call is predictable.
+func testOptions(t *testing.T, store *db.Memory) Options {
+	catalog, err := games.NewMemoryCatalog([]games.Pokemon{
+		{Number: 25, Name: "pikachu", Types: []string{"electric"}, Generation: 1},
+	})
+	assert.NilError(t, err)
+	keys, err := db.NewStaticAPIKeys(map[string]db.APIKey{
+		testAPIKey: {Client: "test-bot", Scopes: db.Roles["admin"]},
+	})
+	assert.NilError(t, err)
+	return Options{
+		Store:   store,
+		Game:    games.NewGame(store, games.GameConfig{Catalog: catalog, Matcher: games.Matcher{CloseWithin: 2}}),
+		Catalog: catalog,
+		Keys:    keys,
+		Admin: AdminOptions{
+			Token:  testAdminToken,
+			Tables: []string{"beemoviebot", "random_*"},
+		},
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func TestAPI_HelloWorldHandler(t *testing.T) {
 	ctx := testcontext.Background()
 	tests := []struct {
@@ -28,13 +104,13 @@ func TestAPI_HelloWorldHandler(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			u, err := url.Parse("http://localhost:8080/api/private/hello")
 			assert.NilError(t, err)
 
-			req := httptest.NewRequest("GET", u.String(), nil)
+			req := newTestRequest("GET", u.String(), nil)
 			a.Router.ServeHTTP(w, req)
 
 			var resp returnBody
@@ -66,7 +142,7 @@ func TestAPI_CreateTable(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			u, err := url.Parse("http://localhost:8080/api/private/create_table")
@@ -74,7 +150,8 @@ func TestAPI_CreateTable(t *testing.T) {
 			body, err := json.Marshal(tt.request)
 			assert.NilError(t, err)
 
-			req := httptest.NewRequest("POST", u.String(), bytes.NewReader(body))
+			req := newTestRequest("POST", u.String(), bytes.NewReader(body))
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
 			a.Router.ServeHTTP(w, req)
 
 			var resp returnBody
@@ -86,6 +163,335 @@ func TestAPI_CreateTable(t *testing.T) {
 	}
 }
 
+func TestAPI_Authentication(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name           string
+		key            string
+		expectedStatus int
+		expectedClient string
+	}{
+		{name: "No key", expectedStatus: 401},
+		{name: "Wrong key", key: "not-the-key", expectedStatus: 401},
+		{name: "Valid key", key: testAPIKey, expectedStatus: 200, expectedClient: "test-bot"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+			assert.NilError(t, err)
+
+			// A route of its own shows what authenticate hands on to the
+			// handlers after it.
+			var client string
+			r := gin.New()
+			r.GET("/", a.authenticate, func(c *gin.Context) {
+				key, ok := ClientFromContext(c.Request.Context())
+				assert.Check(t, ok)
+				client = key.Client
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+			if tt.key != "" {
+				req.Header.Set(apiKeyHeader, tt.key)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Check(t, cmp.Equal(w.Code, tt.expectedStatus))
+			assert.Check(t, cmp.Equal(client, tt.expectedClient))
+			if tt.expectedStatus == 401 {
+				var resp errorBody
+				assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Check(t, cmp.Equal(resp.Code, codeUnauthorized))
+			}
+		})
+	}
+
+	t.Run("Every private route", func(t *testing.T) {
+		a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+		assert.NilError(t, err)
+		for _, route := range a.Router.Routes() {
+			w := httptest.NewRecorder()
+			a.Router.ServeHTTP(w, httptest.NewRequest(route.Method, route.Path, nil))
+			assert.Check(t, cmp.Equal(w.Code, 401), "%s %s", route.Method, route.Path)
+		}
+	})
+}
+
+func TestAPI_Scopes(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name           string
+		method         string
+		route          string
+		request        any
+		scopes         []db.Scope
+		expectedStatus int
+		expectedScope  db.Scope
+	}{
+		{
+			name:           "Bot reads the leaderboard",
+			method:         "GET",
+			route:          "leaderboard?tablename=pokemon_scores",
+			scopes:         db.Roles["bot"],
+			expectedStatus: 200,
+		},
+		{
+			name:           "Bot can not set scores",
+			method:         "POST",
+			route:          "update_user_score",
+			request:        requestBody{TableName: "pokemon_scores", User: "test-user", Score: 100},
+			scopes:         db.Roles["bot"],
+			expectedStatus: 403,
+			expectedScope:  db.ScopeWriteScore,
+		},
+		{
+			name:           "Moderator sets scores",
+			method:         "POST",
+			route:          "update_user_score",
+			request:        requestBody{TableName: "pokemon_scores", User: "test-user", Score: 100},
+			scopes:         db.Roles["moderator"],
+			expectedStatus: 200,
+		},
+		{
+			name:           "Moderator can not manage tables",
+			method:         "POST",
+			route:          "create_table",
+			request:        requestBody{TableName: "random_table"},
+			scopes:         db.Roles["moderator"],
+			expectedStatus: 403,
+			expectedScope:  db.ScopeAdminTables,
+		},
+		{
+			name:           "Every guild needs admin:tables",
+			method:         "GET",
+			route:          "scores/export?tablename=pokemon_scores&all_guilds=true",
+			scopes:         db.Roles["moderator"],
+			expectedStatus: 403,
+			expectedScope:  db.ScopeAdminTables,
+		},
+		{
+			name:           "Scores alone can not play",
+			method:         "POST",
+			route:          "game/start",
+			request:        requestBody{GuildID: "guild", ChannelID: "channel"},
+			scopes:         []db.Scope{db.ScopeWriteScore},
+			expectedStatus: 403,
+			expectedScope:  db.ScopeGamePlay,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, newTestStore(t, map[string]int{"test-user": 1}))
+			keys, err := db.NewStaticAPIKeys(map[string]db.APIKey{testAPIKey: {Client: "test-bot", Scopes: tt.scopes}})
+			assert.NilError(t, err)
+			opts.Keys = keys
+			a, err := New(ctx, opts)
+			assert.NilError(t, err)
+
+			var body io.Reader
+			if tt.request != nil {
+				b, err := json.Marshal(tt.request)
+				assert.NilError(t, err)
+				body = bytes.NewReader(b)
+			}
+			w := httptest.NewRecorder()
+			req := newTestRequest(tt.method, "http://localhost:8080/api/private/"+tt.route, body)
+			req.Header.Set("Authorization", "Bearer "+testAdminToken)
+			a.Router.ServeHTTP(w, req)
+
+			assert.Check(t, cmp.Equal(w.Code, tt.expectedStatus), w.Body.String())
+			if tt.expectedScope != "" {
+				var resp errorBody
+				assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Check(t, cmp.Equal(resp.Code, codeForbidden))
+				assert.Check(t, cmp.Equal(resp.Scope, tt.expectedScope))
+			}
+		})
+	}
+
+	t.Run("Every route but hello needs a scope", func(t *testing.T) {
+		opts := testOptions(t, newTestStore(t, nil))
+		keys, err := db.NewStaticAPIKeys(map[string]db.APIKey{testAPIKey: {Client: "test-bot"}})
+		assert.NilError(t, err)
+		opts.Keys = keys
+		a, err := New(ctx, opts)
+		assert.NilError(t, err)
+		for _, route := range a.Router.Routes() {
+			w := httptest.NewRecorder()
+			a.Router.ServeHTTP(w, newTestRequest(route.Method, route.Path, nil))
+			expected := 403
+			if route.Path == "/api/private/hello" {
+				expected = 200
+			}
+			assert.Check(t, cmp.Equal(w.Code, expected), "%s %s", route.Method, route.Path)
+		}
+	})
+}
+
+func TestAPI_AdminRoutes(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name           string
+		method         string
+		route          string
+		token          string
+		admin          *AdminOptions
+		request        requestBody
+		expectedStatus int
+		expectedCode   string
+		expectedDryRun *dryRunBody
+		expectedTables []string
+	}{
+		{
+			name:           "No token",
+			method:         "POST",
+			route:          "create_table",
+			request:        requestBody{TableName: "random_table"},
+			expectedStatus: 401,
+			expectedCode:   codeUnauthorized,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Wrong token",
+			method:         "DELETE",
+			route:          "delete_table",
+			token:          "not-the-token",
+			request:        requestBody{TableName: "random_scores"},
+			expectedStatus: 401,
+			expectedCode:   codeUnauthorized,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Turned off",
+			method:         "POST",
+			route:          "create_table",
+			token:          testAdminToken,
+			admin:          &AdminOptions{Tables: []string{"random_*"}},
+			request:        requestBody{TableName: "random_table"},
+			expectedStatus: 403,
+			expectedCode:   codeForbidden,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Table not allowed",
+			method:         "DELETE",
+			route:          "delete_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "pokemon_scores"},
+			expectedStatus: 403,
+			expectedCode:   codeForbidden,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Prefix only matches the start",
+			method:         "POST",
+			route:          "create_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "not_random_table"},
+			expectedStatus: 403,
+			expectedCode:   codeForbidden,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Dry run create",
+			method:         "POST",
+			route:          "create_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "random_table", DryRun: true},
+			expectedStatus: 200,
+			expectedDryRun: &dryRunBody{DryRun: true, Action: actionCreate, Table: "random_table"},
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Dry run create of an existing table",
+			method:         "POST",
+			route:          "create_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "random_scores", DryRun: true},
+			expectedStatus: 200,
+			expectedDryRun: &dryRunBody{DryRun: true, Action: actionNone, Table: "random_scores", Exists: true, Scores: 1, Events: 1},
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Dry run delete",
+			method:         "DELETE",
+			route:          "delete_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "random_scores", DryRun: true},
+			expectedStatus: 200,
+			expectedDryRun: &dryRunBody{DryRun: true, Action: actionArchive, Table: "random_scores", Exists: true, Scores: 1, Events: 1},
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Dry run delete of a missing table",
+			method:         "DELETE",
+			route:          "delete_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "random_table", DryRun: true},
+			expectedStatus: 404,
+			expectedCode:   codeNotFound,
+			expectedTables: []string{"pokemon_scores", "random_scores"},
+		},
+		{
+			name:           "Delete",
+			method:         "DELETE",
+			route:          "delete_table",
+			token:          testAdminToken,
+			request:        requestBody{TableName: "random_scores"},
+			expectedStatus: 200,
+			expectedTables: []string{"pokemon_scores"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, nil)
+			_, err := store.CreateTable("random_scores", ctx)
+			assert.NilError(t, err)
+			_, err = store.ChangeScore("random_scores", "", "test-user", db.ScoreChange{Op: db.ScoreIncrement, Amount: 1}, ctx)
+			assert.NilError(t, err)
+
+			opts := testOptions(t, store)
+			if tt.admin != nil {
+				opts.Admin = *tt.admin
+			}
+			a, err := New(ctx, opts)
+			assert.NilError(t, err)
+
+			body, err := json.Marshal(tt.request)
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest(tt.method, "http://localhost:8080/api/private/"+tt.route, bytes.NewReader(body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			a.Router.ServeHTTP(w, req)
+
+			assert.Check(t, cmp.Equal(w.Code, tt.expectedStatus), w.Body.String())
+			switch {
+			case tt.expectedCode != "":
+				var resp errorBody
+				assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Check(t, cmp.Equal(resp.Code, tt.expectedCode))
+				if tt.expectedStatus == 401 {
+					assert.Check(t, cmp.Equal(w.Header().Get("WWW-Authenticate"), `Bearer realm="admin"`))
+				}
+			case tt.expectedDryRun != nil:
+				var resp dryRunBody
+				assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Check(t, cmp.DeepEqual(resp, *tt.expectedDryRun))
+			}
+
+			tables, err := store.ListTables(ctx)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(tables, tt.expectedTables))
+		})
+	}
+}
+
 func TestAPI_ListTables(t *testing.T) {
 	ctx := testcontext.Background()
 	tests := []struct {
@@ -100,13 +506,18 @@ func TestAPI_ListTables(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			store := newTestStore(t, nil)
+			for _, table := range []string{"beemoviebot", "random_table"} {
+				_, err := store.CreateTable(table, ctx)
+				assert.NilError(t, err)
+			}
+			a, err := New(ctx, testOptions(t, store))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			u, err := url.Parse("http://localhost:8080/api/private/list_tables")
 			assert.NilError(t, err)
 
-			req := httptest.NewRequest("GET", u.String(), nil)
+			req := newTestRequest("GET", u.String(), nil)
 			var resp returnBody
 
 			a.Router.ServeHTTP(w, req)
@@ -146,14 +557,14 @@ func TestAPI_UpdateTableWithUser(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			u, err := url.Parse("http://localhost:8080/api/private/update_table_with_user")
 			assert.NilError(t, err)
 			request, err := json.Marshal(tt.request)
 			assert.NilError(t, err)
-			req := httptest.NewRequest("PUT", u.String(), bytes.NewReader(request))
+			req := newTestRequest("PUT", u.String(), bytes.NewReader(request))
 			a.Router.ServeHTTP(w, req)
 			var resp returnBody
 			err = json.NewDecoder(w.Body).Decode(&resp)
@@ -184,18 +595,19 @@ func TestAPI_GetCurrentScoreHandler(t *testing.T) {
 			username:     "test-user-2",
 			score:        0,
 			tableName:    "pokemon_scores",
-			expectedResp: "Score for test-user: 0\n",
+			expectedResp: "Score for test-user-2: 0\n",
 		},
 	}
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{tt.username: tt.score})))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
-			u, err := url.Parse("http://localhost:8080/api/private/get_current_score?username=test-user&tablename=pokemon_scores")
+			formatedURL := fmt.Sprintf("http://localhost:8080/api/private/get_current_score?username=%s&tablename=%s", tt.username, tt.tableName)
+			u, err := url.Parse(formatedURL)
 			assert.NilError(t, err)
-			req := httptest.NewRequest("GET", u.String(), nil)
+			req := newTestRequest("GET", u.String(), nil)
 			a.Router.ServeHTTP(w, req)
 			assert.Check(t, cmp.DeepEqual(w.Body.String(), tt.expectedResp))
 
@@ -238,14 +650,14 @@ func TestAPI_UpdateScoreForUserHandler(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{tt.request.User: 0})))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			u, err := url.Parse("http://localhost:8080/api/private/update_user_score")
 			assert.NilError(t, err)
 			request, err := json.Marshal(tt.request)
 			assert.NilError(t, err)
-			req := httptest.NewRequest("POST", u.String(), bytes.NewReader(request))
+			req := newTestRequest("POST", u.String(), bytes.NewReader(request))
 			a.Router.ServeHTTP(w, req)
 			var resp returnBody
 			err = json.NewDecoder(w.Body).Decode(&resp)
@@ -272,15 +684,967 @@ func TestAPI_LeaderboardHandler(t *testing.T) {
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			a, err := New(ctx)
+			a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{"test-user": 1, "test-user-2": 1})))
 			assert.NilError(t, err)
 			w := httptest.NewRecorder()
 			formatedURL := fmt.Sprintf("http://localhost:8080/api/private/leaderboard?tablename=%s", tt.tableName)
 			u, err := url.Parse(formatedURL)
 			assert.NilError(t, err)
-			req := httptest.NewRequest("GET", u.String(), nil)
+			req := newTestRequest("GET", u.String(), nil)
+			req.Header.Set("Accept", "text/plain")
 			a.Router.ServeHTTP(w, req)
 			assert.Check(t, cmp.DeepEqual(w.Body.String(), tt.expectedResp))
 		})
 	}
 }
+
+func TestAPI_LeaderboardHandler_JSON(t *testing.T) {
+	ctx := testcontext.Background()
+	scores := map[string]int{"ash": 9, "brock": 7, "misty": 7, "gary": 3, "tracey": 0}
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedResp leaderboardBody
+	}{
+		{
+			name:         "First page with ties",
+			query:        "&limit=3",
+			expectedCode: 200,
+			expectedResp: leaderboardBody{
+				Table:  "pokemon_scores",
+				Period: db.PeriodAllTime,
+				Entries: []leaderboardEntry{
+					{Rank: 1, Username: "ash", Score: 9},
+					{Rank: 2, Username: "brock", Score: 7},
+					{Rank: 2, Username: "misty", Score: 7},
+				},
+				Total:      5,
+				Offset:     0,
+				Limit:      3,
+				NextOffset: 3,
+			},
+		},
+		{
+			name:         "Last page",
+			query:        "&offset=3&limit=3",
+			expectedCode: 200,
+			expectedResp: leaderboardBody{
+				Table:  "pokemon_scores",
+				Period: db.PeriodAllTime,
+				Entries: []leaderboardEntry{
+					{Rank: 3, Username: "gary", Score: 3},
+					{Rank: 4, Username: "tracey", Score: 0},
+				},
+				Total:  5,
+				Offset: 3,
+				Limit:  3,
+			},
+		},
+		{
+			name:         "Past the end",
+			query:        "&offset=10",
+			expectedCode: 200,
+			expectedResp: leaderboardBody{Table: "pokemon_scores", Period: db.PeriodAllTime, Entries: []leaderboardEntry{}, Total: 5, Offset: 10, Limit: 10},
+		},
+		{name: "Limit too big", query: "&limit=1000", expectedCode: 400},
+		{name: "Limit not a number", query: "&limit=ten", expectedCode: 400},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newTestStore(t, scores)))
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/leaderboard?tablename=pokemon_scores"+tt.query, nil)
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp leaderboardBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+		})
+	}
+}
+
+func TestAPI_GameHandlers(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name         string
+		guesses      []requestBody
+		expectedResp []guessBody
+	}{
+		{
+			name: "Wrong guess then correct guess",
+			guesses: []requestBody{
+				{GuildID: "guild", ChannelID: "channel", User: "test-user", Guess: "raichu"},
+				{GuildID: "guild", ChannelID: "channel", User: "test-user", Guess: " Pikachu "},
+			},
+			expectedResp: []guessBody{
+				{Correct: false},
+				{Correct: true, Answer: "pikachu", Points: 1, Score: 2},
+			},
+		},
+		{
+			name: "Close guess then fuzzy correct guess",
+			guesses: []requestBody{
+				{GuildID: "guild", ChannelID: "channel", User: "test-user", Guess: "pikachoo"},
+				{GuildID: "guild", ChannelID: "channel", User: "test-user", Guess: "Pika Chu"},
+			},
+			expectedResp: []guessBody{
+				{Correct: false, Close: true, Message: "close!"},
+				{Correct: true, Answer: "pikachu", Points: 1, Score: 2},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newGuildTestStore(t, "guild", map[string]int{"test-user": 1})))
+			assert.NilError(t, err)
+
+			request, err := json.Marshal(requestBody{GuildID: "guild", ChannelID: "channel"})
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("POST", "http://localhost:8080/api/private/game/start", bytes.NewReader(request))
+			a.Router.ServeHTTP(w, req)
+			assert.Equal(t, w.Code, 200)
+
+			var round roundBody
+			err = json.NewDecoder(w.Body).Decode(&round)
+			assert.NilError(t, err)
+			assert.Check(t, round.RoundID != 0)
+
+			for i, guess := range tt.guesses {
+				request, err := json.Marshal(guess)
+				assert.NilError(t, err)
+				w := httptest.NewRecorder()
+				req := newTestRequest("POST", "http://localhost:8080/api/private/game/guess", bytes.NewReader(request))
+				a.Router.ServeHTTP(w, req)
+
+				var resp guessBody
+				err = json.NewDecoder(w.Body).Decode(&resp)
+				assert.NilError(t, err)
+				assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp[i]))
+			}
+
+			request, err = json.Marshal(tt.guesses[len(tt.guesses)-1])
+			assert.NilError(t, err)
+			w = httptest.NewRecorder()
+			req = newTestRequest("POST", "http://localhost:8080/api/private/game/guess", bytes.NewReader(request))
+			a.Router.ServeHTTP(w, req)
+			assert.Check(t, cmp.Equal(w.Code, 404))
+		})
+	}
+}
+
+func TestAPI_HintHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+	assert.NilError(t, err)
+
+	request, err := json.Marshal(requestBody{GuildID: "guild", ChannelID: "channel"})
+	assert.NilError(t, err)
+
+	w := httptest.NewRecorder()
+	req := newTestRequest("POST", "http://localhost:8080/api/private/game/hint", bytes.NewReader(request))
+	a.Router.ServeHTTP(w, req)
+	assert.Check(t, cmp.Equal(w.Code, 404))
+
+	w = httptest.NewRecorder()
+	req = newTestRequest("POST", "http://localhost:8080/api/private/game/start", bytes.NewReader(request))
+	a.Router.ServeHTTP(w, req)
+	assert.Equal(t, w.Code, 200)
+
+	expected := []hintItem{
+		{Kind: "type", Text: "Its primary type is electric."},
+		{Kind: "generation", Text: "It was introduced in Generation 1."},
+	}
+	for i := range expected {
+		w = httptest.NewRecorder()
+		req = newTestRequest("POST", "http://localhost:8080/api/private/game/hint", bytes.NewReader(request))
+		a.Router.ServeHTTP(w, req)
+		assert.Equal(t, w.Code, 200)
+
+		var resp hintBody
+		err = json.NewDecoder(w.Body).Decode(&resp)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(resp, hintBody{Hints: expected[:i+1], Points: 1}))
+	}
+}
+
+func TestAPI_GetPokemonHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedResp string
+	}{
+		{name: "No filter", expectedCode: 200, expectedResp: "pikachu\n"},
+		{name: "Gen 1 easy mode", query: "?generation=1&difficulty=easy&legendary=exclude", expectedCode: 200, expectedResp: "pikachu\n"},
+		{name: "Nothing matches", query: "?generation=2-3", expectedCode: 404},
+		{name: "Bad generation", query: "?generation=kanto", expectedCode: 400},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+			assert.NilError(t, err)
+
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/get_pokemon"+tt.query, nil)
+			a.Router.ServeHTTP(w, req)
+
+			assert.Check(t, cmp.Equal(w.Code, tt.expectedCode))
+			if tt.expectedResp != "" {
+				assert.Check(t, cmp.Equal(w.Body.String(), tt.expectedResp))
+			}
+		})
+	}
+}
+
+func TestAPI_RankHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	scores := map[string]int{"ash": 9, "brock": 7, "misty": 7, "gary": 3, "tracey": 0}
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedResp standingBody
+	}{
+		{
+			name:         "Middle of the table",
+			query:        "&username=misty",
+			expectedCode: 200,
+			expectedResp: standingBody{
+				Rank:       2,
+				Username:   "misty",
+				Score:      7,
+				Percentile: 50,
+				Total:      5,
+				Above: []leaderboardEntry{
+					{Rank: 1, Username: "ash", Score: 9},
+					{Rank: 2, Username: "brock", Score: 7},
+				},
+				Below: []leaderboardEntry{
+					{Rank: 3, Username: "gary", Score: 3},
+					{Rank: 4, Username: "tracey", Score: 0},
+				},
+			},
+		},
+		{
+			name:         "Top of the table",
+			query:        "&username=ash&window=1",
+			expectedCode: 200,
+			expectedResp: standingBody{
+				Rank:       1,
+				Username:   "ash",
+				Score:      9,
+				Percentile: 100,
+				Total:      5,
+				Above:      []leaderboardEntry{},
+				Below:      []leaderboardEntry{{Rank: 2, Username: "brock", Score: 7}},
+			},
+		},
+		{name: "Unknown user", query: "&username=oak", expectedCode: 404},
+		{name: "Missing user", expectedCode: 400},
+		{name: "Window too big", query: "&username=ash&window=100", expectedCode: 400},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newTestStore(t, scores)))
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/rank?tablename=pokemon_scores"+tt.query, nil)
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp standingBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+		})
+	}
+}
+
+func TestAPI_LeaderboardHandler_Period(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newTestStore(t, nil)
+	_, err := store.UpdateTableWithUser("pokemon_scores", "", "ash", ctx)
+	assert.NilError(t, err)
+	_, err = store.UpdateTableWithUser("pokemon_scores", "", "misty", ctx)
+	assert.NilError(t, err)
+
+	store.Now = func() time.Time { return time.Now().AddDate(-1, 0, 0) }
+	_, err = store.ChangeScore("pokemon_scores", "", "ash", db.ScoreChange{Op: db.ScoreSet, Amount: 9}, ctx)
+	assert.NilError(t, err)
+	store.Now = time.Now
+	_, err = store.ChangeScore("pokemon_scores", "", "misty", db.ScoreChange{Op: db.ScoreSet, Amount: 3}, ctx)
+	assert.NilError(t, err)
+	_, err = store.ChangeScore("pokemon_scores", "", "ash", db.ScoreChange{Op: db.ScoreSet, Amount: 10}, ctx)
+	assert.NilError(t, err)
+
+	tests := []struct {
+		period   string
+		expected []leaderboardEntry
+	}{
+		{
+			period: "all-time",
+			expected: []leaderboardEntry{
+				{Rank: 1, Username: "ash", Score: 10},
+				{Rank: 2, Username: "misty", Score: 3},
+			},
+		},
+		{
+			period: "weekly",
+			expected: []leaderboardEntry{
+				{Rank: 1, Username: "misty", Score: 3},
+				{Rank: 2, Username: "ash", Score: 1},
+			},
+		},
+		{
+			period: "daily",
+			expected: []leaderboardEntry{
+				{Rank: 1, Username: "misty", Score: 3},
+				{Rank: 2, Username: "ash", Score: 1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.period, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, store))
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/leaderboard?tablename=pokemon_scores&period="+tt.period, nil)
+			a.Router.ServeHTTP(w, req)
+			assert.Equal(t, w.Code, 200)
+
+			var resp leaderboardBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(string(resp.Period), tt.period))
+			assert.Check(t, cmp.DeepEqual(resp.Entries, tt.expected))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	req := newTestRequest("GET", "http://localhost:8080/api/private/leaderboard?tablename=pokemon_scores&period=yearly", nil)
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+	a.Router.ServeHTTP(w, req)
+	assert.Check(t, cmp.Equal(w.Code, 400))
+}
+
+func TestAPI_UpdateScoreForUserHandler_Operations(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name         string
+		request      requestBody
+		expectedCode int
+		expectedResp scoreBody
+	}{
+		{
+			name:         "Increment",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 2, Operation: "increment"},
+			expectedCode: 200,
+			expectedResp: scoreBody{UpdateAnswer: "the score for the user has been updated", Username: "test-user", Operation: db.ScoreIncrement, Score: 7},
+		},
+		{
+			name:         "Decrement",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 2, Operation: "decrement"},
+			expectedCode: 200,
+			expectedResp: scoreBody{UpdateAnswer: "the score for the user has been updated", Username: "test-user", Operation: db.ScoreDecrement, Score: 3},
+		},
+		{
+			name:         "Reset to zero",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 0, Operation: "set"},
+			expectedCode: 200,
+			expectedResp: scoreBody{UpdateAnswer: "the score for the user has been updated", Username: "test-user", Operation: db.ScoreSet, Score: 0},
+		},
+		{
+			name:         "Set without an operation",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 9, Column: "score"},
+			expectedCode: 200,
+			expectedResp: scoreBody{UpdateAnswer: "the score for the user has been updated", Username: "test-user", Operation: db.ScoreSet, Score: 9},
+		},
+		{
+			name:         "Increment a new user",
+			request:      requestBody{TableName: "pokemon_scores", User: "new-user", Score: 1, Operation: "increment"},
+			expectedCode: 200,
+			expectedResp: scoreBody{UpdateAnswer: "the score for the user has been updated", Username: "new-user", Operation: db.ScoreIncrement, Score: 1},
+		},
+		{
+			name:         "Unknown operation",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 1, Operation: "multiply"},
+			expectedCode: 400,
+		},
+		{
+			name:         "Negative amount",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: -1, Operation: "increment"},
+			expectedCode: 400,
+		},
+		{
+			name:         "Other column",
+			request:      requestBody{TableName: "pokemon_scores", User: "test-user", Score: 1, Column: "id"},
+			expectedCode: 400,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{"test-user": 5})))
+			assert.NilError(t, err)
+			request, err := json.Marshal(tt.request)
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(request))
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp scoreBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+		})
+	}
+}
+
+// TestAPI_UpdateScoreForUserHandler_Concurrent checks concurrent increments
+// are not lost, which they were when the bot read a score and wrote back the
+// total.
+func TestAPI_UpdateScoreForUserHandler_Concurrent(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newTestStore(t, nil)
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+
+	request, err := json.Marshal(requestBody{TableName: "pokemon_scores", User: "test-user", Score: 1, Operation: "increment"})
+	assert.NilError(t, err)
+
+	const increments = 50
+	var wg sync.WaitGroup
+	for range increments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(request))
+			a.Router.ServeHTTP(w, req)
+			assert.Check(t, cmp.Equal(w.Code, 200))
+		}()
+	}
+	wg.Wait()
+
+	score, err := store.GetCurrentScore("pokemon_scores", "", "test-user", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(score, increments))
+}
+
+func TestAPI_ScoreHistoryHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	at := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	store := newTestStore(t, nil)
+	store.Now = func() time.Time { return at }
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+
+	for _, change := range []requestBody{
+		{TableName: "pokemon_scores", User: "test-user", Score: 5},
+		{TableName: "pokemon_scores", User: "test-user", Score: 2, Operation: "increment", Reason: "bonus", OnBehalfOf: "oak"},
+		{TableName: "pokemon_scores", User: "test-user", Score: 1, Operation: "decrement"},
+		{TableName: "pokemon_scores", User: "other-user", Score: 4, Operation: "increment"},
+	} {
+		request, err := json.Marshal(change)
+		assert.NilError(t, err)
+		w := httptest.NewRecorder()
+		req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(request))
+		a.Router.ServeHTTP(w, req)
+		assert.Assert(t, cmp.Equal(w.Code, 200))
+	}
+
+	tests := []struct {
+		name         string
+		query        string
+		expectedCode int
+		expectedResp scoreHistoryBody
+	}{
+		{
+			name:         "First page",
+			query:        "&username=test-user&limit=2",
+			expectedCode: 200,
+			expectedResp: scoreHistoryBody{
+				Table:    "pokemon_scores",
+				Username: "test-user",
+				Entries: []scoreHistoryEntry{
+					{ID: 3, Delta: -1, Score: 6, Reason: "decrement", Actor: "test-bot", CreatedAt: at},
+					{ID: 2, Delta: 2, Score: 7, Reason: "bonus", Actor: "test-bot", OnBehalfOf: "oak", CreatedAt: at},
+				},
+				Total:      3,
+				Limit:      2,
+				NextOffset: 2,
+			},
+		},
+		{
+			name:         "Last page",
+			query:        "&username=test-user&limit=2&offset=2",
+			expectedCode: 200,
+			expectedResp: scoreHistoryBody{
+				Table:    "pokemon_scores",
+				Username: "test-user",
+				Entries: []scoreHistoryEntry{
+					{ID: 1, Delta: 5, Score: 5, Reason: "set", Actor: "test-bot", CreatedAt: at},
+				},
+				Total:  3,
+				Offset: 2,
+				Limit:  2,
+			},
+		},
+		{
+			name:         "No changes",
+			query:        "&username=oak",
+			expectedCode: 200,
+			expectedResp: scoreHistoryBody{
+				Table:    "pokemon_scores",
+				Username: "oak",
+				Entries:  []scoreHistoryEntry{},
+				Limit:    db.DefaultPageLimit,
+			},
+		},
+		{name: "Missing user", expectedCode: 400},
+		{name: "Bad limit", query: "&username=test-user&limit=lots", expectedCode: 400},
+		{name: "Limit too big", query: "&username=test-user&limit=1000", expectedCode: 400},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/score_history?tablename=pokemon_scores"+tt.query, nil)
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp scoreHistoryBody
+			err := json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+		})
+	}
+}
+
+// TestAPI_GuildIsolation checks scores, answers and leaderboards in one guild
+// are not seen from another, or from clients that send no guild.
+func TestAPI_GuildIsolation(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newTestStore(t, map[string]int{"ash": 1})
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+
+	for _, change := range []requestBody{
+		{TableName: "pokemon_scores", GuildID: "kanto", User: "ash", Score: 5, Operation: "increment"},
+		{TableName: "pokemon_scores", GuildID: "kanto", User: "misty", Score: 3, Operation: "increment"},
+		{TableName: "pokemon_scores", GuildID: "johto", User: "ash", Score: 8, Operation: "increment"},
+	} {
+		request, err := json.Marshal(change)
+		assert.NilError(t, err)
+		w := httptest.NewRecorder()
+		req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(request))
+		a.Router.ServeHTTP(w, req)
+		assert.Assert(t, cmp.Equal(w.Code, 200))
+	}
+	_, err = store.PutAnswerInDB("pokemon_answers", "kanto", "mew", 151, ctx)
+	assert.NilError(t, err)
+	_, err = store.PutAnswerInDB("pokemon_answers", "johto", "celebi", 251, ctx)
+	assert.NilError(t, err)
+
+	t.Run("Scores", func(t *testing.T) {
+		for guild, expected := range map[string]string{"": "Score for ash: 1\n", "kanto": "Score for ash: 5\n", "johto": "Score for ash: 8\n"} {
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/get_current_score?tablename=pokemon_scores&username=ash&guild_id="+guild, nil)
+			a.Router.ServeHTTP(w, req)
+			assert.Check(t, cmp.Equal(w.Body.String(), expected), "guild %q", guild)
+		}
+	})
+
+	t.Run("Leaderboard", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := newTestRequest("GET", "http://localhost:8080/api/private/leaderboard?tablename=pokemon_scores&guild_id=kanto", nil)
+		a.Router.ServeHTTP(w, req)
+		assert.Assert(t, cmp.Equal(w.Code, 200))
+
+		var resp leaderboardBody
+		err := json.NewDecoder(w.Body).Decode(&resp)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.DeepEqual(resp, leaderboardBody{
+			Table:   "pokemon_scores",
+			GuildID: "kanto",
+			Period:  db.PeriodAllTime,
+			Entries: []leaderboardEntry{
+				{Rank: 1, Username: "ash", Score: 5},
+				{Rank: 2, Username: "misty", Score: 3},
+			},
+			Total: 2,
+			Limit: db.DefaultPageLimit,
+		}))
+	})
+
+	t.Run("Rank", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req := newTestRequest("GET", "http://localhost:8080/api/private/rank?tablename=pokemon_scores&username=misty&guild_id=johto", nil)
+		a.Router.ServeHTTP(w, req)
+		assert.Check(t, cmp.Equal(w.Code, 404))
+	})
+
+	t.Run("Answers", func(t *testing.T) {
+		for guild, expected := range map[string]string{"kanto": "mew", "johto": "celebi"} {
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/get_answer?tablename=pokemon_answers&colum=ANSWER&guild_id="+guild, nil)
+			a.Router.ServeHTTP(w, req)
+			assert.Check(t, cmp.Equal(w.Body.String(), expected), "guild %q", guild)
+		}
+
+		w := httptest.NewRecorder()
+		req := newTestRequest("GET", "http://localhost:8080/api/private/get_answer?tablename=pokemon_answers&colum=ANSWER", nil)
+		a.Router.ServeHTTP(w, req)
+		assert.Check(t, cmp.Equal(w.Code, 404))
+	})
+}
+
+// TestAPI_UserRename checks a user identified by their Discord ID keeps their
+// score and history when they change their name.
+func TestAPI_UserRename(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newTestStore(t, nil)
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+
+	for _, change := range []requestBody{
+		{TableName: "pokemon_scores", UserID: "1001", User: "ash", Score: 5, Operation: "increment"},
+		{TableName: "pokemon_scores", UserID: "1001", User: "ash-ketchum", Score: 2, Operation: "increment"},
+	} {
+		request, err := json.Marshal(change)
+		assert.NilError(t, err)
+		w := httptest.NewRecorder()
+		req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(request))
+		a.Router.ServeHTTP(w, req)
+		assert.Assert(t, cmp.Equal(w.Code, 200))
+
+		var resp scoreBody
+		err = json.NewDecoder(w.Body).Decode(&resp)
+		assert.NilError(t, err)
+		assert.Check(t, cmp.Equal(resp.Username, change.User))
+	}
+
+	leaderboard, err := store.Leaderboard("pokemon_scores", "", db.LeaderboardPage{}, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(leaderboard.Entries, []db.LeaderboardEntry{{Rank: 1, Username: "ash-ketchum", Score: 7}}))
+
+	history, err := store.ScoreHistory("pokemon_scores", "", "ash-ketchum", db.Page{}, ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(history.Total, 2))
+
+	w := httptest.NewRecorder()
+	req := newTestRequest("GET", "http://localhost:8080/api/private/user?user_id=1001", nil)
+	a.Router.ServeHTTP(w, req)
+	assert.Assert(t, cmp.Equal(w.Code, 200))
+
+	var resp userBody
+	err = json.NewDecoder(w.Body).Decode(&resp)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(resp.Name, "ash-ketchum"))
+	assert.Assert(t, cmp.Len(resp.Names, 2))
+	assert.Check(t, cmp.Equal(resp.Names[0].Name, "ash"))
+	assert.Check(t, cmp.Equal(resp.Names[1].Name, "ash-ketchum"))
+
+	w = httptest.NewRecorder()
+	req = newTestRequest("GET", "http://localhost:8080/api/private/user?user_id=2002", nil)
+	a.Router.ServeHTTP(w, req)
+	assert.Check(t, cmp.Equal(w.Code, 404))
+}
+
+func TestAPI_MergeUsersHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name         string
+		identify     map[string]string
+		request      requestBody
+		expectedCode int
+		expectedResp mergeBody
+	}{
+		{
+			name:         "Split by a rename",
+			request:      requestBody{TableName: "pokemon_scores", From: "red", Into: "red-2"},
+			expectedCode: 200,
+			expectedResp: mergeBody{Merged: "red", Username: "red-2", Score: 8},
+		},
+		{
+			name:         "One side identified",
+			identify:     map[string]string{"red": "1001"},
+			request:      requestBody{TableName: "pokemon_scores", From: "red", Into: "red-2"},
+			expectedCode: 200,
+			expectedResp: mergeBody{Merged: "red", Username: "red-2", Score: 8},
+		},
+		{
+			name:         "Different users",
+			identify:     map[string]string{"red": "1001", "red-2": "1002"},
+			request:      requestBody{TableName: "pokemon_scores", From: "red", Into: "red-2"},
+			expectedCode: 409,
+		},
+		{
+			name:         "Unknown user",
+			request:      requestBody{TableName: "pokemon_scores", From: "blue", Into: "red-2"},
+			expectedCode: 404,
+		},
+		{
+			name:         "Same user",
+			request:      requestBody{TableName: "pokemon_scores", From: "red", Into: "red"},
+			expectedCode: 400,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, map[string]int{"red": 5, "red-2": 3})
+			for username, discordID := range tt.identify {
+				_, err := store.IdentifyUser("pokemon_scores", "", discordID, username, ctx)
+				assert.NilError(t, err)
+			}
+			a, err := New(ctx, testOptions(t, store))
+			assert.NilError(t, err)
+
+			request, err := json.Marshal(tt.request)
+			assert.NilError(t, err)
+			w := httptest.NewRecorder()
+			req := newTestRequest("POST", "http://localhost:8080/api/private/users/merge", bytes.NewReader(request))
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp mergeBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+
+			history, err := store.ScoreHistory("pokemon_scores", "", "red-2", db.Page{}, ctx)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(history.Total, 2))
+		})
+	}
+}
+
+// TestAPI_UserRename_Split checks a rename onto a name that already has a
+// score is refused until the two are merged.
+func TestAPI_UserRename_Split(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newTestStore(t, map[string]int{"red": 5, "red-2": 3})
+	_, err := store.IdentifyUser("pokemon_scores", "", "1001", "red", ctx)
+	assert.NilError(t, err)
+
+	_, err = store.IdentifyUser("pokemon_scores", "", "1001", "red-2", ctx)
+	assert.Check(t, errors.Is(err, db.ErrConflict), "got: %v", err)
+
+	score, err := store.MergeUsers("pokemon_scores", "", "red-2", "red", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(score, 8))
+
+	_, err = store.IdentifyUser("pokemon_scores", "", "1001", "red-2", ctx)
+	assert.NilError(t, err)
+	score, err = store.GetCurrentScore("pokemon_scores", "", "red-2", ctx)
+	assert.NilError(t, err)
+	assert.Check(t, cmp.Equal(score, 8))
+}
+
+func TestAPI_ExportScoresHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name         string
+		query        string
+		accept       string
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "CSV",
+			query:        "tablename=pokemon_scores&guild_id=kanto",
+			expectedCode: 200,
+			expectedType: "text/csv",
+			expectedBody: "guild_id,username,score\nkanto,brock,4\n",
+		},
+		{
+			name:         "NDJSON from the Accept header",
+			query:        "tablename=pokemon_scores&all_guilds=true",
+			accept:       "application/x-ndjson",
+			expectedCode: 200,
+			expectedType: "application/x-ndjson",
+			expectedBody: `{"guild_id":"","username":"ash","score":9}` + "\n" +
+				`{"guild_id":"","username":"misty","score":0}` + "\n" +
+				`{"guild_id":"kanto","username":"brock","score":4}` + "\n",
+		},
+		{
+			name:         "Empty table",
+			query:        "tablename=empty_scores&guild_id=kanto&format=csv",
+			expectedCode: 200,
+			expectedType: "text/csv",
+			expectedBody: "guild_id,username,score\n",
+		},
+		{name: "Unknown table", query: "tablename=missing_scores&guild_id=kanto", expectedCode: 404},
+		{name: "Unknown format", query: "tablename=pokemon_scores&guild_id=kanto&format=xml", expectedCode: 400},
+		{name: "Missing table", expectedCode: 400},
+		{
+			name:         "Shared guild",
+			query:        "tablename=pokemon_scores&guild_id=",
+			expectedCode: 200,
+			expectedType: "text/csv",
+			expectedBody: "guild_id,username,score\n,ash,9\n,misty,0\n",
+		},
+		{name: "Missing guild", query: "tablename=pokemon_scores", expectedCode: 400},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, map[string]int{"ash": 9, "misty": 0})
+			_, err := store.ChangeScore("pokemon_scores", "kanto", "brock", db.ScoreChange{Op: db.ScoreSet, Amount: 4}, ctx)
+			assert.NilError(t, err)
+			_, err = store.CreateTable("empty_scores", ctx)
+			assert.NilError(t, err)
+			a, err := New(ctx, testOptions(t, store))
+			assert.NilError(t, err)
+
+			w := httptest.NewRecorder()
+			req := newTestRequest("GET", "http://localhost:8080/api/private/scores/export?"+tt.query, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			assert.Check(t, cmp.Equal(w.Header().Get("Content-Type"), tt.expectedType))
+			assert.Check(t, cmp.Equal(w.Body.String(), tt.expectedBody))
+		})
+	}
+}
+
+func TestAPI_ImportScoresHandler(t *testing.T) {
+	ctx := testcontext.Background()
+	tests := []struct {
+		name           string
+		query          string
+		contentType    string
+		body           string
+		expectedCode   int
+		expectedResp   importBody
+		expectedScores map[string]int
+	}{
+		{
+			name:        "CSV with bad rows",
+			query:       "tablename=pokemon_scores&guild_id=",
+			contentType: "text/csv",
+			body: "username,score\n" +
+				"ash,12\n" +
+				"misty,lots\n" +
+				",3\n" +
+				"brock,5\n" +
+				"ash,1\n",
+			expectedCode: 200,
+			expectedResp: importBody{
+				Table:    "pokemon_scores",
+				Imported: 2,
+				Errors: []rowErrorBody{
+					{Line: 3, Error: `score must be a number, got "lots"`},
+					{Line: 4, Error: "invalid input: the user must not be empty"},
+					{Line: 6, Error: "invalid input: ash is already on line 2"},
+				},
+			},
+			expectedScores: map[string]int{"ash": 12, "brock": 5, "misty": 2},
+		},
+		{
+			name:        "NDJSON into a guild",
+			query:       "tablename=pokemon_scores&guild_id=kanto",
+			contentType: "application/x-ndjson",
+			body: `{"username": "ash", "score": 7}` + "\n" +
+				"\n" +
+				`{"username": "misty"}` + "\n" +
+				`{"username": "brock", "score": 1, "guild_id": "johto"}` + "\n" +
+				`not json` + "\n",
+			expectedCode: 200,
+			expectedResp: importBody{
+				Table:    "pokemon_scores",
+				Imported: 1,
+				Errors: []rowErrorBody{
+					{Line: 3, Error: "score is required"},
+					{Line: 4, Error: `invalid input: the row is for guild "johto", not "kanto"`},
+					{Line: 5, Error: "invalid character 'o' in literal null (expecting 'u')"},
+				},
+			},
+			expectedScores: map[string]int{"ash": 1, "misty": 2},
+		},
+		{
+			name:         "CSV without a score column",
+			query:        "tablename=pokemon_scores&guild_id=kanto",
+			body:         "username\nash\n",
+			expectedCode: 400,
+		},
+		{
+			name:         "Unknown table",
+			query:        "tablename=missing_scores&guild_id=kanto",
+			body:         "username,score\nash,1\n",
+			expectedCode: 404,
+		},
+		{
+			name:         "Missing guild",
+			query:        "tablename=pokemon_scores",
+			body:         "username,score\nash,1\n",
+			expectedCode: 400,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, map[string]int{"ash": 1, "misty": 2})
+			a, err := New(ctx, testOptions(t, store))
+			assert.NilError(t, err)
+
+			w := httptest.NewRecorder()
+			req := newTestRequest("POST", "http://localhost:8080/api/private/scores/import?"+tt.query, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			a.Router.ServeHTTP(w, req)
+
+			assert.Equal(t, w.Code, tt.expectedCode)
+			if tt.expectedCode != 200 {
+				return
+			}
+			var resp importBody
+			err = json.NewDecoder(w.Body).Decode(&resp)
+			assert.NilError(t, err)
+			assert.Check(t, cmp.DeepEqual(resp, tt.expectedResp))
+
+			for username, expected := range tt.expectedScores {
+				score, err := store.GetCurrentScore("pokemon_scores", "", username, ctx)
+				assert.NilError(t, err)
+				assert.Check(t, cmp.Equal(score, expected), username)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// apiKeyHeader is the header clients send their API key in.
+const apiKeyHeader = "X-API-Key"
+
+type clientKey struct{}
+
+// ClientFromContext returns the API key the request was made with.
+func ClientFromContext(ctx context.Context) (db.APIKey, bool) {
+	key, ok := ctx.Value(clientKey{}).(db.APIKey)
+	return key, ok
+}
+
+// authenticate stops requests without a valid API key or signature, and
+// attaches the client they belong to to the request context and its trace.
+func (a *API) authenticate(c *gin.Context) {
+	if a.signing != nil && c.GetHeader(signatureHeader) != "" {
+		a.authenticateSigned(c)
+		return
+	}
+	ctx := c.Request.Context()
+	o11y.AddFieldToTrace(ctx, "auth-mode", "api-key")
+
+	key := c.GetHeader(apiKeyHeader)
+	if key == "" {
+		writeUnauthorized(c, "an api key is required in the "+apiKeyHeader+" header")
+		return
+	}
+	apiKey, err := a.keys.LookupAPIKey(ctx, key)
+	if errors.Is(err, db.ErrNotFound) {
+		writeUnauthorized(c, "the api key is not valid")
+		return
+	}
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "auth-error", err)
+		writeError(c, err)
+		c.Abort()
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "client", apiKey.Client)
+	o11y.AddFieldToTrace(ctx, "api-key-prefix", apiKey.Prefix)
+	c.Request = c.Request.WithContext(context.WithValue(ctx, clientKey{}, apiKey))
+	c.Next()
+}
+
+// requireScope stops requests whose API key does not have scope. Whether the
+// request was let through is added to its trace either way.
+func (a *API) requireScope(scope db.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		apiKey, _ := ClientFromContext(ctx)
+		o11y.AddFieldToTrace(ctx, "auth-scope", scope)
+		if !apiKey.HasScope(scope) {
+			o11y.AddFieldToTrace(ctx, "auth-decision", "denied")
+			writeMissingScope(c, apiKey, scope)
+			return
+		}
+		o11y.AddFieldToTrace(ctx, "auth-decision", "allowed")
+		c.Next()
+	}
+}
+
+// writeMissingScope writes a forbidden response naming the scope apiKey is
+// missing, and aborts the request.
+func writeMissingScope(c *gin.Context, apiKey db.APIKey, scope db.Scope) {
+	c.AbortWithStatusJSON(http.StatusForbidden, errorBody{
+		Error: fmt.Sprintf("the api key for %s is missing the %s scope", apiKey.Client, scope),
+		Code:  codeForbidden,
+		Scope: scope,
+	})
+}
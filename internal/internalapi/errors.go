@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// errorBody is the envelope every error response is written with. Scope is
+// set when a request was turned away for a scope its API key is missing.
+type errorBody struct {
+	Error string   `json:"error"`
+	Code  string   `json:"code"`
+	Scope db.Scope `json:"scope,omitempty"`
+}
+
+const (
+	codeBadRequest   = "bad_request"
+	codeUnauthorized = "unauthorized"
+	codeForbidden    = "forbidden"
+	codeNotFound     = "not_found"
+	codeConflict     = "conflict"
+	codeRateLimited  = "rate_limited"
+	codeUnavailable  = "unavailable"
+	codeInternal     = "internal"
+)
+
+// errorStatus maps an error returned from the db package onto the status
+// code and error code it should be reported with.
+func errorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, db.ErrInvalidIdentifier), errors.Is(err, db.ErrInvalidInput):
+		return http.StatusBadRequest, codeBadRequest
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound, codeNotFound
+	case errors.Is(err, db.ErrConflict):
+		return http.StatusConflict, codeConflict
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable, codeUnavailable
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+func writeError(c *gin.Context, err error) {
+	status, code := errorStatus(err)
+	c.JSON(status, errorBody{Error: err.Error(), Code: code})
+}
+
+func writeBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, errorBody{Error: message, Code: codeBadRequest})
+}
+
+// writeUnauthorized and writeForbidden also abort the request, so they can be
+// used from middleware.
+func writeUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, errorBody{Error: message, Code: codeUnauthorized})
+}
+
+func writeForbidden(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, errorBody{Error: message, Code: codeForbidden})
+}
@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+)
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+		expectedCode   string
+	}{
+		{
+			name:           "Invalid identifier",
+			err:            fmt.Errorf("%w: the table name must not be empty", db.ErrInvalidIdentifier),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   codeBadRequest,
+		},
+		{
+			name:           "Invalid input",
+			err:            fmt.Errorf("%w: the user must not be empty", db.ErrInvalidInput),
+			expectedStatus: http.StatusBadRequest,
+			expectedCode:   codeBadRequest,
+		},
+		{
+			name:           "Not found",
+			err:            fmt.Errorf("there was an error finding the answer: %w", db.ErrNotFound),
+			expectedStatus: http.StatusNotFound,
+			expectedCode:   codeNotFound,
+		},
+		{
+			name:           "Conflict",
+			err:            fmt.Errorf("there was an error updating the table: %w", db.ErrConflict),
+			expectedStatus: http.StatusConflict,
+			expectedCode:   codeConflict,
+		},
+		{
+			name:           "Unavailable",
+			err:            fmt.Errorf("failed to query tables: %w", db.ErrUnavailable),
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   codeUnavailable,
+		},
+		{
+			name:           "Unknown error",
+			err:            errors.New("something went wrong"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedCode:   codeInternal,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			status, code := errorStatus(tt.err)
+			assert.Equal(t, status, tt.expectedStatus)
+			assert.Equal(t, code, tt.expectedCode)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+	"github.com/imlogang/api-service/internal/games"
+)
+
+type roundBody struct {
+	RoundID   int64     `json:"round_id"`
+	StartedAt time.Time `json:"started_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type hintBody struct {
+	Hints  []hintItem `json:"hints"`
+	Points int        `json:"points"`
+}
+
+type hintItem struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+}
+
+// closeMessage is sent back with a guess that was nearly right.
+const closeMessage = "close!"
+
+type guessBody struct {
+	Correct bool   `json:"correct"`
+	Close   bool   `json:"close,omitempty"`
+	Message string `json:"message,omitempty"`
+	Answer  string `json:"answer,omitempty"`
+	Points  int    `json:"points,omitempty"`
+	Score   int    `json:"score,omitempty"`
+}
+
+func (a *API) StartRoundHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var requestBody requestBody
+	err := c.BindJSON(&requestBody)
+	ctx, startRoundSpan := o11y.StartSpan(ctx, "StartRoundHandler")
+	defer o11y.End(startRoundSpan, &err)
+
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "start-round", requestBody)
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	filter, err := games.ParseFilter(requestBody.Generation, requestBody.Legendary, requestBody.Difficulty)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	round, err := a.game.Start(ctx, requestBody.GuildID, requestBody.ChannelID, filter)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "round-id", round.ID)
+	c.JSON(http.StatusOK, newRoundBody(round))
+}
+
+func (a *API) GuessHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var requestBody requestBody
+	err := c.BindJSON(&requestBody)
+	ctx, guessSpan := o11y.StartSpan(ctx, "GuessHandler")
+	defer o11y.End(guessSpan, &err)
+
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "guess", requestBody)
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	err = a.identifyUser(ctx, db.PokemonScoresTable, requestBody.GuildID, requestBody.UserID, requestBody.User)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	result, err := a.game.Guess(ctx, requestBody.GuildID, requestBody.ChannelID, requestBody.User, requestBody.Guess)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "correct", result.Correct)
+	c.JSON(http.StatusOK, newGuessBody(result))
+}
+
+func newGuessBody(result games.GuessResult) guessBody {
+	body := guessBody{
+		Correct: result.Correct,
+		Close:   result.Close,
+		Answer:  result.Answer,
+		Points:  result.Points,
+		Score:   result.Score,
+	}
+	if result.Close {
+		body.Message = closeMessage
+	}
+	return body
+}
+
+func (a *API) HintHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var requestBody requestBody
+	err := c.BindJSON(&requestBody)
+	ctx, hintSpan := o11y.StartSpan(ctx, "HintHandler")
+	defer o11y.End(hintSpan, &err)
+
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "hint", requestBody)
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	result, err := a.game.Hint(ctx, requestBody.GuildID, requestBody.ChannelID)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "hints-used", len(result.Hints))
+	c.JSON(http.StatusOK, newHintBody(result))
+}
+
+func newHintBody(result games.HintResult) hintBody {
+	hints := make([]hintItem, 0, len(result.Hints))
+	for _, hint := range result.Hints {
+		hints = append(hints, hintItem{Kind: hint.Kind, Text: hint.Text})
+	}
+	return hintBody{Hints: hints, Points: result.Points}
+}
+
+func newRoundBody(round db.Round) roundBody {
+	return roundBody{
+		RoundID:   round.ID,
+		StartedAt: round.StartedAt,
+		ExpiresAt: round.ExpiresAt,
+	}
+}
@@ -0,0 +1,94 @@
+package httpapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+type scoreHistoryBody struct {
+	Table      string              `json:"table"`
+	GuildID    string              `json:"guild_id,omitempty"`
+	Username   string              `json:"username"`
+	Entries    []scoreHistoryEntry `json:"entries"`
+	Total      int                 `json:"total"`
+	Offset     int                 `json:"offset"`
+	Limit      int                 `json:"limit"`
+	NextOffset int                 `json:"next_offset,omitempty"`
+}
+
+type scoreHistoryEntry struct {
+	ID         int64     `json:"id"`
+	Delta      int       `json:"delta"`
+	Score      int       `json:"score"`
+	Reason     string    `json:"reason"`
+	Actor      string    `json:"actor,omitempty"`
+	OnBehalfOf string    `json:"on_behalf_of,omitempty"`
+	RoundID    int64     `json:"round_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newScoreHistoryBody(tableName string, guildID string, username string, history db.ScoreHistory) scoreHistoryBody {
+	entries := make([]scoreHistoryEntry, 0, len(history.Events))
+	for _, e := range history.Events {
+		entries = append(entries, scoreHistoryEntry{
+			ID:         e.ID,
+			Delta:      e.Delta,
+			Score:      e.Score,
+			Reason:     e.Reason,
+			Actor:      e.Actor,
+			OnBehalfOf: e.OnBehalfOf,
+			RoundID:    e.RoundID,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	return scoreHistoryBody{
+		Table:      tableName,
+		GuildID:    guildID,
+		Username:   username,
+		Entries:    entries,
+		Total:      history.Total,
+		Offset:     history.Page.Offset,
+		Limit:      history.Page.Limit,
+		NextOffset: history.NextOffset(),
+	}
+}
+
+// ScoreHistoryHandler pages through the changes to a user's score in a
+// guild's table, newest first.
+func (a *API) ScoreHistoryHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Query("tablename")
+	guildID := c.Query("guild_id")
+	username := c.Query("username")
+
+	var err error
+	ctx, scoreHistoryHandlerSpan := o11y.StartSpan(ctx, "ScoreHistoryHandler")
+	defer o11y.End(scoreHistoryHandlerSpan, &err)
+
+	if tableName == "" || username == "" {
+		o11y.AddFieldToTrace(ctx, "table-name", tableName)
+		writeBadRequest(c, "tablename and username required")
+		return
+	}
+
+	page, err := pageQuery(c)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	history, err := a.store.ScoreHistory(tableName, guildID, username, page, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "score-changes", history.Total)
+	c.JSON(http.StatusOK, newScoreHistoryBody(tableName, guildID, username, history))
+}
@@ -2,32 +2,87 @@ package httpapi
 
 import (
 	"context"
+	"errors"
 	"github.com/circleci/ex/httpserver/ginrouter"
 	"github.com/circleci/ex/o11y"
 	"github.com/circleci/ex/o11y/wrappers/o11ygin"
 	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+	"github.com/imlogang/api-service/internal/games"
+	"time"
 )
 
 type API struct {
-	Router *gin.Engine
+	Router     *gin.Engine
+	store      db.Store
+	game       *games.Game
+	catalog    games.Catalog
+	admin      AdminOptions
+	keys       db.APIKeyStore
+	signing    *verifier
+	rateLimits RateLimitOptions
 }
 
-func New(ctx context.Context) (*API, error) {
+type Options struct {
+	Store   db.Store
+	Game    *games.Game
+	Catalog games.Catalog
+	Admin   AdminOptions
+	// Keys are the API keys every request under /api/v1 and /api/private
+	// must carry one of.
+	Keys db.APIKeyStore
+	// Signing lets clients sign their requests instead. It is off when
+	// there are no secrets.
+	Signing SigningOptions
+	// RateLimits limit how often each client calls each route.
+	RateLimits RateLimitOptions
+}
+
+func New(ctx context.Context, opts Options) (*API, error) {
+	if opts.Keys == nil {
+		return nil, errors.New("api keys are required")
+	}
 	r := ginrouter.Default(ctx, "internal")
 	r.Use(o11ygin.ClientCancelled())
 
-	a := &API{Router: r}
+	a := &API{Router: r, store: opts.Store, game: opts.Game, catalog: opts.Catalog, admin: opts.Admin, keys: opts.Keys}
+	if len(opts.Signing.Secrets) > 0 {
+		a.signing = newVerifier(opts.Signing)
+	}
+	a.rateLimits = opts.RateLimits
+	if a.rateLimits.Now == nil {
+		a.rateLimits.Now = time.Now
+	}
 	o11y.Log(ctx, "New Internal router is called")
-	r.GET("/api/private/hello", a.HelloWorldHandler)
-	r.GET("/api/private/list_tables", a.ListTablesHandler)
-	r.POST("/api/private/create_table", a.CreateTableHandler)
-	r.DELETE("/api/private/delete_table", a.DeleteTableHandler)
-	r.GET("/api/private/get_answer", a.ReadAnswerFromDBHandler)
-	r.GET("/api/private/get_current_score", a.GetScoreHandler)
-	r.POST("/api/private/update_user_score", a.UpdateScoreForUserHandler)
-	r.GET("/api/private/get_pokemon", a.GetPokemonHandler)
-	r.GET("/api/private/leaderboard", a.LeaderboardHandler)
-	r.PUT("/api/private/update_table_with_user", a.UpdateTableWithUserHandler)
+	a.v1Routes(r.Group("/api/v1", a.authenticate, a.rateLimit("/api/v1/")))
+
+	// The /api/private routes are kept for clients that have not moved to
+	// /api/v1. Every route but hello needs its API key to have a scope for
+	// it.
+	private := r.Group("/api/private", a.deprecated, a.authenticate, a.rateLimit("/api/private/"))
+	private.GET("/hello", a.HelloWorldHandler)
+	private.GET("/list_tables", a.requireScope(db.ScopeReadLeaderboard), a.ListTablesHandler)
+	private.POST("/create_table", a.requireScope(db.ScopeAdminTables), a.requireAdmin, a.CreateTableHandler)
+	private.DELETE("/delete_table", a.requireScope(db.ScopeAdminTables), a.requireAdmin, a.DeleteTableHandler)
+	private.GET("/get_answer", a.requireScope(db.ScopeGamePlay), a.ReadAnswerFromDBHandler)
+	private.GET("/get_current_score", a.requireScope(db.ScopeReadLeaderboard), a.GetScoreHandler)
+	private.POST("/update_user_score", a.requireScope(db.ScopeWriteScore), a.UpdateScoreForUserHandler)
+	private.GET("/get_pokemon", a.requireScope(db.ScopeGamePlay), a.GetPokemonHandler)
+	private.GET("/leaderboard", a.requireScope(db.ScopeReadLeaderboard), a.LeaderboardHandler)
+	private.GET("/rank", a.requireScope(db.ScopeReadLeaderboard), a.RankHandler)
+	private.GET("/score_history", a.requireScope(db.ScopeReadLeaderboard), a.ScoreHistoryHandler)
+	private.GET("/scores/export", a.requireScope(db.ScopeReadLeaderboard), a.ExportScoresHandler)
+	private.POST("/scores/import", a.requireScope(db.ScopeWriteScore), a.ImportScoresHandler)
+	private.PUT("/update_table_with_user", a.requireScope(db.ScopeWriteScore), a.UpdateTableWithUserHandler)
+	private.GET("/user", a.requireScope(db.ScopeReadLeaderboard), a.GetUserHandler)
+	private.POST("/users/merge", a.requireScope(db.ScopeWriteScore), a.MergeUsersHandler)
+	private.POST("/game/start", a.requireScope(db.ScopeGamePlay), a.StartRoundHandler)
+	private.POST("/game/guess", a.requireScope(db.ScopeGamePlay), a.GuessHandler)
+	private.POST("/game/hint", a.requireScope(db.ScopeGamePlay), a.HintHandler)
+
+	if err := a.rateLimits.checkRoutes(r, "/api/v1/", "/api/private/"); err != nil {
+		return nil, err
+	}
 
 	return a, nil
 }
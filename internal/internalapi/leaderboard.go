@@ -0,0 +1,167 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// defaultStandingWindow is how many players either side of the user a rank
+// lookup shows when the request does not say.
+const defaultStandingWindow = 2
+
+type leaderboardBody struct {
+	Table      string             `json:"table"`
+	GuildID    string             `json:"guild_id,omitempty"`
+	Period     db.Period          `json:"period"`
+	Since      *time.Time         `json:"since,omitempty"`
+	Entries    []leaderboardEntry `json:"entries"`
+	Total      int                `json:"total"`
+	Offset     int                `json:"offset"`
+	Limit      int                `json:"limit"`
+	NextOffset int                `json:"next_offset,omitempty"`
+}
+
+type leaderboardEntry struct {
+	Rank     int    `json:"rank"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+type standingBody struct {
+	Rank       int                `json:"rank"`
+	Username   string             `json:"username"`
+	Score      int                `json:"score"`
+	Percentile float64            `json:"percentile"`
+	Total      int                `json:"total"`
+	Above      []leaderboardEntry `json:"above"`
+	Below      []leaderboardEntry `json:"below"`
+}
+
+func newLeaderboardEntries(entries []db.LeaderboardEntry) []leaderboardEntry {
+	body := make([]leaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		body = append(body, leaderboardEntry{Rank: entry.Rank, Username: entry.Username, Score: entry.Score})
+	}
+	return body
+}
+
+func newLeaderboardBody(tableName string, guildID string, period db.Period, leaderboard db.Leaderboard) leaderboardBody {
+	var since *time.Time
+	if !leaderboard.Page.Since.IsZero() {
+		since = &leaderboard.Page.Since
+	}
+	return leaderboardBody{
+		Table:      tableName,
+		GuildID:    guildID,
+		Period:     period,
+		Since:      since,
+		Entries:    newLeaderboardEntries(leaderboard.Entries),
+		Total:      leaderboard.Total,
+		Offset:     leaderboard.Page.Offset,
+		Limit:      leaderboard.Page.Limit,
+		NextOffset: leaderboard.NextOffset(),
+	}
+}
+
+func newStandingBody(standing db.Standing) standingBody {
+	return standingBody{
+		Rank:       standing.Rank,
+		Username:   standing.Username,
+		Score:      standing.Score,
+		Percentile: standing.Percentile,
+		Total:      standing.Total,
+		Above:      newLeaderboardEntries(standing.Above),
+		Below:      newLeaderboardEntries(standing.Below),
+	}
+}
+
+// standingWindow reads the window query parameter.
+func standingWindow(c *gin.Context) (int, error) {
+	w := c.Query("window")
+	if w == "" {
+		return defaultStandingWindow, nil
+	}
+	window, err := strconv.Atoi(w)
+	if err != nil {
+		return 0, fmt.Errorf("window must be a number, got %q", w)
+	}
+	return window, nil
+}
+
+// leaderboardPage reads the period, offset and limit query parameters.
+// Range checks are left to the store.
+func leaderboardPage(c *gin.Context, now time.Time) (db.Period, db.LeaderboardPage, error) {
+	var page db.LeaderboardPage
+	period, err := db.ParsePeriod(c.Query("period"))
+	if err != nil {
+		return "", db.LeaderboardPage{}, err
+	}
+	page.Since = period.Since(now)
+	page.Page, err = pageQuery(c)
+	if err != nil {
+		return "", db.LeaderboardPage{}, err
+	}
+	return period, page, nil
+}
+
+// pageQuery reads the offset and limit query parameters. Range checks are
+// left to the store.
+func pageQuery(c *gin.Context) (db.Page, error) {
+	var page db.Page
+	var err error
+	if offset := c.Query("offset"); offset != "" {
+		page.Offset, err = strconv.Atoi(offset)
+		if err != nil {
+			return db.Page{}, fmt.Errorf("offset must be a number, got %q", offset)
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		page.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return db.Page{}, fmt.Errorf("limit must be a number, got %q", limit)
+		}
+	}
+	return page, nil
+}
+
+// RankHandler returns a user's rank and percentile in their guild along with
+// the players just above and below them.
+func (a *API) RankHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Query("tablename")
+	guildID := c.Query("guild_id")
+	username := c.Query("username")
+
+	var err error
+	ctx, rankHandlerSpan := o11y.StartSpan(ctx, "RankHandler")
+	defer o11y.End(rankHandlerSpan, &err)
+
+	if tableName == "" || username == "" {
+		o11y.AddFieldToTrace(ctx, "table-name", tableName)
+		writeBadRequest(c, "tablename and username required")
+		return
+	}
+
+	window, err := standingWindow(c)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	standing, err := a.store.Standing(tableName, guildID, username, window, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "rank", standing.Rank)
+	c.JSON(http.StatusOK, newStandingBody(standing))
+}
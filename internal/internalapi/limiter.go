@@ -0,0 +1,140 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// RateLimit is a token bucket that holds Burst requests and refills at
+// Requests every Per.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	Burst    int
+}
+
+// IsZero reports whether the limit is unset, meaning there is no limit.
+func (l RateLimit) IsZero() bool {
+	return l.Requests == 0
+}
+
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s:%d", l.Requests, l.Per, l.Burst)
+}
+
+// interval is how long the bucket takes to refill one request.
+func (l RateLimit) interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// ParseRateLimit parses a limit written as requests/period, such as 30/1m
+// or 30/m, optionally followed by :burst. The burst defaults to the number
+// of requests.
+func ParseRateLimit(s string) (RateLimit, error) {
+	rate, burst, hasBurst := strings.Cut(s, ":")
+	requests, per, ok := strings.Cut(rate, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("%w: the rate limit %q must be requests/period", db.ErrInvalidInput, s)
+	}
+	var l RateLimit
+	var err error
+	l.Requests, err = strconv.Atoi(requests)
+	if err != nil || l.Requests <= 0 {
+		return RateLimit{}, fmt.Errorf("%w: the rate limit %q must allow a positive number of requests", db.ErrInvalidInput, s)
+	}
+	if per != "" && (per[0] < '0' || per[0] > '9') {
+		per = "1" + per
+	}
+	l.Per, err = time.ParseDuration(per)
+	if err != nil || l.Per <= 0 {
+		return RateLimit{}, fmt.Errorf("%w: the rate limit %q must have a positive period", db.ErrInvalidInput, s)
+	}
+	l.Burst = l.Requests
+	if hasBurst {
+		l.Burst, err = strconv.Atoi(burst)
+		if err != nil || l.Burst <= 0 {
+			return RateLimit{}, fmt.Errorf("%w: the rate limit %q must have a positive burst", db.ErrInvalidInput, s)
+		}
+	}
+	return l, nil
+}
+
+// RateDecision is whether a request was let through by a rate limit, and
+// what is left of it.
+type RateDecision struct {
+	Allowed   bool
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be let through.
+	// It is zero when one would be now.
+	RetryAfter time.Duration
+}
+
+// RateLimiter keeps the token buckets requests are limited by.
+type RateLimiter interface {
+	// Take takes a request from the bucket for key, which is filled
+	// according to limit.
+	Take(ctx context.Context, key string, limit RateLimit, now time.Time) (RateDecision, error)
+}
+
+// MemoryRateLimiter keeps token buckets in memory, so each replica limits
+// the requests it sees on its own.
+type MemoryRateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]bucket
+	swept   time.Time
+}
+
+// bucket is a token bucket that was left with tokens at updated, and is
+// full again at full.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time
+}
+
+func NewMemoryRateLimiter() *MemoryRateLimiter {
+	return &MemoryRateLimiter{buckets: map[string]bucket{}}
+}
+
+func (m *MemoryRateLimiter) Take(_ context.Context, key string, limit RateLimit, now time.Time) (RateDecision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Full buckets are the same as ones never used, so they are dropped
+	// now and then to keep one-off keys from piling up.
+	if now.Sub(m.swept) > time.Minute {
+		for k, b := range m.buckets {
+			if !now.Before(b.full) {
+				delete(m.buckets, k)
+			}
+		}
+		m.swept = now
+	}
+
+	interval := limit.interval()
+	tokens := float64(limit.Burst)
+	if b, ok := m.buckets[key]; ok {
+		tokens = math.Min(tokens, b.tokens+float64(now.Sub(b.updated))/float64(interval))
+	}
+
+	var decision RateDecision
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - tokens) * float64(interval))
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = time.Duration((float64(limit.Burst) - tokens) * float64(interval))
+	m.buckets[key] = bucket{tokens: tokens, updated: now, full: now.Add(decision.Reset)}
+	return decision, nil
+}
@@ -0,0 +1,174 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+)
+
+// The headers a rate limited response is sent with, from the IETF
+// RateLimit header fields draft.
+const (
+	rateLimitHeader          = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+	rateLimitResetHeader     = "RateLimit-Reset"
+	rateLimitPolicyHeader    = "RateLimit-Policy"
+)
+
+// maxUsernameBytes is how much of a JSON body is read looking for the
+// username to limit by.
+const maxUsernameBytes = 64 << 10
+
+// RateLimitOptions limits how often each client can call each route.
+type RateLimitOptions struct {
+	// Limiter keeps the buckets. Rate limiting is off without one.
+	Limiter RateLimiter
+	// Default is the limit for routes without one of their own. Routes
+	// are not limited when it is zero.
+	Default RateLimit
+	// Routes are limits for single routes, keyed by their path under
+	// /api/v1 or /api/private, such as pokemon or get_pokemon.
+	Routes map[string]RateLimit
+	// PerUser are routes whose limit also applies to each username a
+	// client sends separately, rather than to the client as a whole.
+	PerUser []string
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// limit returns the limit for route, and whether it is kept per user.
+func (o RateLimitOptions) limit(route string) (RateLimit, bool) {
+	limit, ok := o.Routes[route]
+	if !ok {
+		limit = o.Default
+	}
+	for _, r := range o.PerUser {
+		if r == route {
+			return limit, true
+		}
+	}
+	return limit, false
+}
+
+// checkRoutes returns an error for any route named in the options that the
+// router does not have, so a typo does not quietly leave a route unlimited.
+func (o RateLimitOptions) checkRoutes(r *gin.Engine, prefixes ...string) error {
+	known := map[string]bool{}
+	for _, route := range r.Routes() {
+		for _, prefix := range prefixes {
+			if name, ok := strings.CutPrefix(route.Path, prefix); ok {
+				known[name] = true
+			}
+		}
+	}
+	for route := range o.Routes {
+		if !known[route] {
+			return fmt.Errorf("there is no route %s to rate limit", route)
+		}
+	}
+	for _, route := range o.PerUser {
+		if !known[route] {
+			return fmt.Errorf("there is no route %s to rate limit per user", route)
+		}
+	}
+	return nil
+}
+
+// rateLimit turns away requests once their client, or the user they are
+// for, has used up the limit for the route.
+func (a *API) rateLimit(prefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a.rateLimits.Limiter == nil {
+			c.Next()
+			return
+		}
+		route := strings.TrimPrefix(c.FullPath(), prefix)
+		limit, perUser := a.rateLimits.limit(route)
+		if limit.IsZero() {
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		apiKey, _ := ClientFromContext(ctx)
+		key := apiKey.Client + "\n" + route
+		if perUser {
+			if username := requestUsername(c); username != "" {
+				key += "\n" + username
+				o11y.AddFieldToTrace(ctx, "rate-limit-username", username)
+			}
+		}
+		o11y.AddFieldToTrace(ctx, "rate-limit-route", route)
+		o11y.AddFieldToTrace(ctx, "rate-limit", limit)
+
+		decision, err := a.rateLimits.Limiter.Take(ctx, key, limit, a.rateLimits.Now())
+		if err != nil {
+			// A limiter that can not be reached should not take the
+			// API down with it.
+			o11y.AddFieldToTrace(ctx, "rate-limit-error", err)
+			c.Next()
+			return
+		}
+
+		c.Header(rateLimitHeader, strconv.Itoa(limit.Burst))
+		c.Header(rateLimitRemainingHeader, strconv.Itoa(decision.Remaining))
+		c.Header(rateLimitResetHeader, seconds(decision.Reset))
+		c.Header(rateLimitPolicyHeader, fmt.Sprintf("%d;w=%s;burst=%d", limit.Requests, seconds(limit.Per), limit.Burst))
+		o11y.AddFieldToTrace(ctx, "rate-limit-remaining", decision.Remaining)
+		if !decision.Allowed {
+			o11y.AddFieldToTrace(ctx, "rate-limit-decision", "limited")
+			c.Header("Retry-After", seconds(decision.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorBody{
+				Error: fmt.Sprintf("too many requests to %s, try again in %s seconds", route, seconds(decision.RetryAfter)),
+				Code:  codeRateLimited,
+			})
+			return
+		}
+		o11y.AddFieldToTrace(ctx, "rate-limit-decision", "allowed")
+		c.Next()
+	}
+}
+
+// seconds rounds d up to whole seconds, as the RateLimit and Retry-After
+// headers want.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
+
+// requestUsername finds the username a request is for, in its path, its
+// query or its JSON body. The body is left to be read again.
+func requestUsername(c *gin.Context) string {
+	if username := c.Param("user"); username != "" {
+		return username
+	}
+	if username := c.Query("username"); username != "" {
+		return username
+	}
+	if c.Request.Body == nil || c.ContentType() != "application/json" {
+		return ""
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxUsernameBytes))
+	c.Request.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), c.Request.Body), c.Request.Body}
+	if err != nil {
+		return ""
+	}
+	var request struct {
+		Username string `json:"username"`
+	}
+	if json.Unmarshal(body, &request) != nil {
+		return ""
+	}
+	return request.Username
+}
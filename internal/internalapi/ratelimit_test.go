@@ -0,0 +1,161 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestAPI_RateLimit(t *testing.T) {
+	ctx := testcontext.Background()
+	now := time.Date(2025, 1, 15, 20, 30, 0, 0, time.UTC)
+	opts := testOptions(t, newTestStore(t, map[string]int{"ash": 1, "misty": 1}))
+	opts.RateLimits = RateLimitOptions{
+		Limiter: NewMemoryRateLimiter(),
+		Default: RateLimit{Requests: 5, Per: time.Minute, Burst: 5},
+		Routes: map[string]RateLimit{
+			"get_pokemon":       {Requests: 2, Per: time.Minute, Burst: 2},
+			"update_user_score": {Requests: 1, Per: time.Minute, Burst: 1},
+		},
+		PerUser: []string{"update_user_score"},
+		Now:     func() time.Time { return now },
+	}
+	a, err := New(ctx, opts)
+	assert.NilError(t, err)
+
+	getPokemon := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		a.Router.ServeHTTP(w, newTestRequest("GET", "http://localhost:8080/api/private/get_pokemon", nil))
+		return w
+	}
+	updateScore := func(username string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(requestBody{TableName: "pokemon_scores", User: username, Score: 3})
+		assert.NilError(t, err)
+		w := httptest.NewRecorder()
+		req := newTestRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		a.Router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Route limit", func(t *testing.T) {
+		w := getPokemon()
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitHeader), "2"))
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitRemainingHeader), "1"))
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitResetHeader), "30"))
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitPolicyHeader), "2;w=60;burst=2"))
+
+		assert.Check(t, cmp.Equal(getPokemon().Code, 200))
+
+		w = getPokemon()
+		assert.Check(t, cmp.Equal(w.Code, 429), w.Body.String())
+		assert.Check(t, cmp.Equal(w.Header().Get("Retry-After"), "30"))
+		var resp errorBody
+		assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Check(t, cmp.Equal(resp.Code, codeRateLimited))
+	})
+
+	t.Run("Default limit", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		a.Router.ServeHTTP(w, newTestRequest("GET", "http://localhost:8080/api/private/hello", nil))
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitRemainingHeader), "4"))
+	})
+
+	t.Run("Per user limit", func(t *testing.T) {
+		w := updateScore("ash")
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+		// The body is still there for the handler once the username has
+		// been read from it.
+		var resp scoreBody
+		assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+		assert.Check(t, cmp.Equal(resp.Score, 3))
+
+		assert.Check(t, cmp.Equal(updateScore("ash").Code, 429))
+		assert.Check(t, cmp.Equal(updateScore("misty").Code, 200))
+	})
+
+	t.Run("Unknown route", func(t *testing.T) {
+		opts := testOptions(t, newTestStore(t, nil))
+		opts.RateLimits = RateLimitOptions{
+			Limiter: NewMemoryRateLimiter(),
+			Routes:  map[string]RateLimit{"get_pokemans": {Requests: 1, Per: time.Minute, Burst: 1}},
+		}
+		_, err := New(ctx, opts)
+		assert.Check(t, cmp.ErrorContains(err, "get_pokemans"))
+	})
+
+	t.Run("Off without a limiter", func(t *testing.T) {
+		a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+		assert.NilError(t, err)
+		w := httptest.NewRecorder()
+		a.Router.ServeHTTP(w, newTestRequest("GET", "http://localhost:8080/api/private/hello", nil))
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+		assert.Check(t, cmp.Equal(w.Header().Get(rateLimitHeader), ""))
+	})
+}
+
+func TestParseRateLimit(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected RateLimit
+		wantErr  bool
+	}{
+		{in: "30/1m", expected: RateLimit{Requests: 30, Per: time.Minute, Burst: 30}},
+		{in: "30/m", expected: RateLimit{Requests: 30, Per: time.Minute, Burst: 30}},
+		{in: "5/10s:20", expected: RateLimit{Requests: 5, Per: 10 * time.Second, Burst: 20}},
+		{in: "30", wantErr: true},
+		{in: "0/m", wantErr: true},
+		{in: "30/fortnight", wantErr: true},
+		{in: "30/-1m", wantErr: true},
+		{in: "30/m:0", wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.in, func(t *testing.T) {
+			limit, err := ParseRateLimit(tt.in)
+			if tt.wantErr {
+				assert.Check(t, errors.Is(err, db.ErrInvalidInput), "got: %v", err)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, cmp.Equal(limit, tt.expected))
+		})
+	}
+}
+
+func TestMemoryRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 15, 20, 30, 0, 0, time.UTC)
+	limit := RateLimit{Requests: 1, Per: 10 * time.Second, Burst: 2}
+	limiter := NewMemoryRateLimiter()
+
+	take := func(key string, at time.Time) RateDecision {
+		t.Helper()
+		decision, err := limiter.Take(ctx, key, limit, at)
+		assert.NilError(t, err)
+		return decision
+	}
+
+	assert.Check(t, cmp.DeepEqual(take("bot", now), RateDecision{Allowed: true, Remaining: 1, Reset: 10 * time.Second}))
+	assert.Check(t, cmp.DeepEqual(take("bot", now), RateDecision{Allowed: true, Remaining: 0, Reset: 20 * time.Second}))
+	assert.Check(t, cmp.DeepEqual(take("bot", now.Add(5*time.Second)),
+		RateDecision{Allowed: false, Remaining: 0, Reset: 15 * time.Second, RetryAfter: 5 * time.Second}))
+
+	// Other keys have buckets of their own.
+	assert.Check(t, take("dashboard", now).Allowed)
+
+	// The bucket refills a request every ten seconds, up to the burst.
+	assert.Check(t, cmp.DeepEqual(take("bot", now.Add(10*time.Second)), RateDecision{Allowed: true, Remaining: 0, Reset: 20 * time.Second}))
+	assert.Check(t, cmp.DeepEqual(take("bot", now.Add(time.Hour)), RateDecision{Allowed: true, Remaining: 1, Reset: 10 * time.Second}))
+}
@@ -0,0 +1,236 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+// The headers a signed request is sent with.
+const (
+	signatureClientHeader    = "X-Signature-Client"
+	signatureTimestampHeader = "X-Signature-Timestamp"
+	signatureNonceHeader     = "X-Signature-Nonce"
+	signatureHeader          = "X-Signature"
+)
+
+// DefaultMaxClockSkew is how far a signed request's timestamp can be from
+// the server's clock when SigningOptions does not say.
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// maxNonceLength keeps clients from filling the nonce cache with large
+// nonces.
+const maxNonceLength = 128
+
+// maxSignedBodyBytes caps how much of a signed request's body is read to
+// check its signature, other than for imports which can be up to
+// maxImportBytes.
+const maxSignedBodyBytes = 1 << 20
+
+// importRoutes are the routes whose signed bodies can be as large as an
+// import.
+var importRoutes = map[string]bool{
+	"POST /api/private/scores/import":   true,
+	"POST /api/v1/tables/:table/scores": true,
+}
+
+// SigningOptions turns on signed requests, where a client signs each request
+// with a secret it shares with the service rather than sending an API key.
+type SigningOptions struct {
+	// Secrets are keyed by client.
+	Secrets map[string]SigningSecret
+	// MaxClockSkew is how far a request's timestamp can be from now, either
+	// way.
+	MaxClockSkew time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// SigningSecret is a secret a client signs its requests with instead of
+// sending an API key.
+type SigningSecret struct {
+	db.APIKey
+	Secret []byte
+}
+
+// signingKeyPrefix is the Prefix given to keys that sign their requests.
+const signingKeyPrefix = "signed"
+
+// NewSigningSecrets takes a map of secrets to the client each belongs to and
+// what it can do, and returns them by client, so each client can only have
+// one.
+func NewSigningSecrets(keys map[string]db.APIKey) (map[string]SigningSecret, error) {
+	secrets := map[string]SigningSecret{}
+	for secret, key := range keys {
+		if _, ok := secrets[key.Client]; ok {
+			return nil, fmt.Errorf("%w: %s has more than one signing secret", db.ErrInvalidInput, key.Client)
+		}
+		key.Prefix = signingKeyPrefix
+		secrets[key.Client] = SigningSecret{APIKey: key, Secret: []byte(secret)}
+	}
+	return secrets, nil
+}
+
+// StringToSign is what a request is signed over: its method, path and
+// query, timestamp, nonce, and a SHA-256 hash of its body, each on its own
+// line.
+func StringToSign(method string, requestURI string, timestamp string, nonce string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.Join([]string{method, requestURI, timestamp, nonce, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// Sign returns the hex HMAC-SHA256 of StringToSign with secret.
+func Sign(secret []byte, stringToSign string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(stringToSign))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest signs req, whose body must be body, as client.
+func SignRequest(req *http.Request, client string, secret []byte, nonce string, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(signatureClientHeader, client)
+	req.Header.Set(signatureTimestampHeader, timestamp)
+	req.Header.Set(signatureNonceHeader, nonce)
+	req.Header.Set(signatureHeader, Sign(secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body)))
+}
+
+// errBadSignature is why a signed request was turned away.
+var errBadSignature = errors.New("the request signature is not valid")
+
+// verifier checks signed requests.
+type verifier struct {
+	opts   SigningOptions
+	nonces *nonceCache
+}
+
+func newVerifier(opts SigningOptions) *verifier {
+	if opts.MaxClockSkew <= 0 {
+		opts.MaxClockSkew = DefaultMaxClockSkew
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &verifier{opts: opts, nonces: newNonceCache()}
+}
+
+// verify checks the signature on req, reading up to maxBody bytes of its body
+// to do so and leaving it to be read again. It returns the key of the client
+// that signed it.
+func (v *verifier) verify(req *http.Request, maxBody int64) (db.APIKey, error) {
+	client := req.Header.Get(signatureClientHeader)
+	timestamp := req.Header.Get(signatureTimestampHeader)
+	nonce := req.Header.Get(signatureNonceHeader)
+	signature := req.Header.Get(signatureHeader)
+	if client == "" || timestamp == "" || nonce == "" || signature == "" {
+		return db.APIKey{}, fmt.Errorf("%w: %s, %s, %s and %s are all required", errBadSignature,
+			signatureClientHeader, signatureTimestampHeader, signatureNonceHeader, signatureHeader)
+	}
+	if len(nonce) > maxNonceLength {
+		return db.APIKey{}, fmt.Errorf("%w: the nonce must be at most %d characters", errBadSignature, maxNonceLength)
+	}
+	secret, ok := v.opts.Secrets[client]
+	if !ok {
+		// Which clients exist is kept off the response, for unauthenticated
+		// callers to not learn from.
+		o11y.AddFieldToTrace(req.Context(), "signature-client", client)
+		o11y.AddFieldToTrace(req.Context(), "signature-error", "unknown client")
+		return db.APIKey{}, errBadSignature
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return db.APIKey{}, fmt.Errorf("%w: the timestamp must be in unix seconds", errBadSignature)
+	}
+	now := v.opts.Now()
+	signedAt := time.Unix(unix, 0)
+	if skew := now.Sub(signedAt).Abs(); skew > v.opts.MaxClockSkew {
+		return db.APIKey{}, fmt.Errorf("%w: the timestamp is %s from the server's clock, more than the %s allowed", errBadSignature, skew.Round(time.Second), v.opts.MaxClockSkew)
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, req.Body, maxBody))
+	if err != nil {
+		return db.APIKey{}, fmt.Errorf("%w: the body could not be read: %w", errBadSignature, err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	expected := Sign(secret.Secret, StringToSign(req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return db.APIKey{}, errBadSignature
+	}
+	// The nonce is only used up once the signature is known to be good, so
+	// others can not burn a client's nonces.
+	if !v.nonces.add(client+"\n"+nonce, signedAt.Add(v.opts.MaxClockSkew), now) {
+		return db.APIKey{}, fmt.Errorf("%w: the nonce has already been used", errBadSignature)
+	}
+	return secret.APIKey, nil
+}
+
+// authenticateSigned is authenticate for signed requests.
+func (a *API) authenticateSigned(c *gin.Context) {
+	ctx := c.Request.Context()
+	o11y.AddFieldToTrace(ctx, "auth-mode", "signed")
+
+	maxBody := int64(maxSignedBodyBytes)
+	if importRoutes[c.Request.Method+" "+c.FullPath()] {
+		maxBody = maxImportBytes
+	}
+	apiKey, err := a.signing.verify(c.Request, maxBody)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "auth-error", err)
+		writeUnauthorized(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "client", apiKey.Client)
+	c.Request = c.Request.WithContext(context.WithValue(ctx, clientKey{}, apiKey))
+	c.Next()
+}
+
+// nonceCache remembers the nonces of signed requests until they are too old
+// to be replayed. It is kept per replica, so a request replayed to another
+// replica is only stopped by the clock skew window.
+type nonceCache struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	swept   time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{expires: map[string]time.Time{}}
+}
+
+// add records a nonce that can be replayed until expires, and reports
+// whether it was new.
+func (n *nonceCache) add(nonce string, expires time.Time, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if now.Sub(n.swept) > time.Minute {
+		for k, e := range n.expires {
+			if now.After(e) {
+				delete(n.expires, k)
+			}
+		}
+		n.swept = now
+	}
+	if e, ok := n.expires[nonce]; ok && !now.After(e) {
+		return false
+	}
+	n.expires[nonce] = expires
+	return true
+}
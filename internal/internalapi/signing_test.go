@@ -0,0 +1,170 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+func TestAPI_SignedRequests(t *testing.T) {
+	ctx := testcontext.Background()
+	now := time.Date(2025, 1, 15, 20, 30, 0, 0, time.UTC)
+	secret := []byte("test-signing-secret")
+	body, err := json.Marshal(requestBody{TableName: "pokemon_scores", User: "test-user", Score: 3})
+	assert.NilError(t, err)
+	tooLarge := append(bytes.Repeat([]byte(" "), maxSignedBodyBytes), body...)
+
+	tests := []struct {
+		name           string
+		client         string
+		secret         []byte
+		signedAt       time.Time
+		signedBody     []byte
+		sentBody       []byte
+		nonce          string
+		replay         bool
+		expectedStatus int
+	}{
+		{name: "Signed", expectedStatus: 200},
+		{name: "Signed a little in the future", signedAt: now.Add(50 * time.Second), expectedStatus: 200},
+		{name: "Body changed", sentBody: []byte(`{"table_name":"pokemon_scores","username":"test-user","score":300}`), expectedStatus: 401},
+		{name: "Wrong secret", secret: []byte("not-the-secret"), expectedStatus: 401},
+		{name: "Unknown client", client: "someone-else", expectedStatus: 401},
+		{name: "Too old", signedAt: now.Add(-2 * time.Minute), expectedStatus: 401},
+		{name: "Too far in the future", signedAt: now.Add(2 * time.Minute), expectedStatus: 401},
+		{name: "Replayed", replay: true, expectedStatus: 401},
+		{name: "Body too large", signedBody: tooLarge, sentBody: tooLarge, expectedStatus: 401},
+		{name: "Missing the bot's scope", client: "read-only", expectedStatus: 403},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, newTestStore(t, map[string]int{"test-user": 1}))
+			opts.Signing = SigningOptions{
+				Secrets: map[string]SigningSecret{
+					"test-bot":  {APIKey: db.APIKey{Client: "test-bot", Scopes: db.Roles["moderator"]}, Secret: secret},
+					"read-only": {APIKey: db.APIKey{Client: "read-only", Scopes: db.Roles["bot"]}, Secret: secret},
+				},
+				MaxClockSkew: time.Minute,
+				Now:          func() time.Time { return now },
+			}
+			a, err := New(ctx, opts)
+			assert.NilError(t, err)
+
+			client, key, signedAt, nonce := "test-bot", secret, now, "nonce-1"
+			if tt.client != "" {
+				client = tt.client
+			}
+			if tt.secret != nil {
+				key = tt.secret
+			}
+			if !tt.signedAt.IsZero() {
+				signedAt = tt.signedAt
+			}
+			signedBody, sentBody := body, body
+			if tt.signedBody != nil {
+				signedBody = tt.signedBody
+			}
+			if tt.sentBody != nil {
+				sentBody = tt.sentBody
+			}
+
+			send := func() *httptest.ResponseRecorder {
+				w := httptest.NewRecorder()
+				req := httptest.NewRequest("POST", "http://localhost:8080/api/private/update_user_score", bytes.NewReader(sentBody))
+				SignRequest(req, client, key, nonce, signedBody, signedAt)
+				a.Router.ServeHTTP(w, req)
+				return w
+			}
+			w := send()
+			if tt.replay {
+				assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+				w = send()
+			}
+
+			assert.Check(t, cmp.Equal(w.Code, tt.expectedStatus), w.Body.String())
+			assert.Check(t, !strings.Contains(w.Body.String(), "someone-else"), w.Body.String())
+			if tt.expectedStatus == 200 {
+				var resp scoreBody
+				assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+				assert.Check(t, cmp.Equal(resp.Score, 3))
+			}
+		})
+	}
+
+	t.Run("Imports can be larger", func(t *testing.T) {
+		opts := testOptions(t, newTestStore(t, nil))
+		opts.Signing = SigningOptions{
+			Secrets: map[string]SigningSecret{"test-bot": {APIKey: db.APIKey{Client: "test-bot", Scopes: db.Roles["moderator"]}, Secret: secret}},
+			Now:     func() time.Time { return now },
+		}
+		a, err := New(ctx, opts)
+		assert.NilError(t, err)
+
+		var csv bytes.Buffer
+		csv.WriteString("username,score\n")
+		for i := 0; csv.Len() <= maxSignedBodyBytes; i++ {
+			fmt.Fprintf(&csv, "user-%d,1\n", i)
+		}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "http://localhost:8080/api/private/scores/import?tablename=pokemon_scores&guild_id=kanto", bytes.NewReader(csv.Bytes()))
+		req.Header.Set("Content-Type", "text/csv")
+		SignRequest(req, "test-bot", secret, "nonce-1", csv.Bytes(), now)
+		a.Router.ServeHTTP(w, req)
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+	})
+
+	t.Run("API keys still work", func(t *testing.T) {
+		opts := testOptions(t, newTestStore(t, nil))
+		opts.Signing = SigningOptions{Secrets: map[string]SigningSecret{"test-bot": {APIKey: db.APIKey{Client: "test-bot"}, Secret: secret}}}
+		a, err := New(ctx, opts)
+		assert.NilError(t, err)
+
+		w := httptest.NewRecorder()
+		a.Router.ServeHTTP(w, newTestRequest("GET", "http://localhost:8080/api/private/leaderboard?tablename=pokemon_scores", nil))
+		assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+	})
+}
+
+func TestNonceCache(t *testing.T) {
+	now := time.Date(2025, 1, 15, 20, 30, 0, 0, time.UTC)
+	n := newNonceCache()
+
+	assert.Check(t, n.add("a", now.Add(time.Minute), now))
+	assert.Check(t, !n.add("a", now.Add(time.Minute), now.Add(30*time.Second)))
+	assert.Check(t, n.add("b", now.Add(time.Minute), now.Add(30*time.Second)))
+
+	// Once a nonce's request is too old to be accepted it is forgotten.
+	later := now.Add(2 * time.Minute)
+	assert.Check(t, n.add("a", later.Add(time.Minute), later))
+	assert.Check(t, cmp.Len(n.expires, 1))
+}
+
+func TestNewSigningSecrets(t *testing.T) {
+	secrets, err := NewSigningSecrets(map[string]db.APIKey{
+		"bot-secret": {Client: "discord-bot", Scopes: db.Roles["moderator"]},
+	})
+	assert.NilError(t, err)
+	assert.Check(t, cmp.DeepEqual(secrets, map[string]SigningSecret{
+		"discord-bot": {
+			APIKey: db.APIKey{Client: "discord-bot", Prefix: signingKeyPrefix, Scopes: db.Roles["moderator"]},
+			Secret: []byte("bot-secret"),
+		},
+	}))
+
+	_, err = NewSigningSecrets(map[string]db.APIKey{
+		"bot-secret":   {Client: "discord-bot"},
+		"other-secret": {Client: "discord-bot"},
+	})
+	assert.Check(t, errors.Is(err, db.ErrInvalidInput), "got: %v", err)
+}
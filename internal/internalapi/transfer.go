@@ -0,0 +1,375 @@
+package httpapi
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	mimeCSV    = "text/csv"
+	mimeNDJSON = "application/x-ndjson"
+)
+
+// maxImportBytes caps the size of an import body.
+const maxImportBytes = 32 << 20
+
+// exportFlushEvery is how many rows are written between flushes, so a
+// large export reaches the client as it goes.
+const exportFlushEvery = 500
+
+var csvHeader = []string{"guild_id", "username", "score"}
+
+type scoreRowBody struct {
+	GuildID  string `json:"guild_id"`
+	Username string `json:"username"`
+	Score    *int   `json:"score"`
+}
+
+type importBody struct {
+	Table    string         `json:"table"`
+	Imported int            `json:"imported"`
+	Errors   []rowErrorBody `json:"errors"`
+}
+
+type rowErrorBody struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// transferFormat picks csv or ndjson from the format query parameter, or
+// failing that from a media type, defaulting to csv.
+func transferFormat(c *gin.Context, mediaType string) (string, error) {
+	switch format := c.Query("format"); format {
+	case formatCSV, formatNDJSON:
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("format must be %s or %s, got %q", formatCSV, formatNDJSON, format)
+	}
+	if mediaType, _, err := mime.ParseMediaType(mediaType); err == nil && mediaType == mimeNDJSON {
+		return formatNDJSON, nil
+	}
+	return formatCSV, nil
+}
+
+// scoreWriter writes exported rows in either format. Nothing is written
+// until the first row, so an error before then can still be sent as an
+// error response.
+type scoreWriter struct {
+	c       *gin.Context
+	format  string
+	csv     *csv.Writer
+	json    *json.Encoder
+	written int
+}
+
+func (w *scoreWriter) start() error {
+	if w.format == formatNDJSON {
+		w.c.Header("Content-Type", mimeNDJSON)
+		w.json = json.NewEncoder(w.c.Writer)
+		return nil
+	}
+	w.c.Header("Content-Type", mimeCSV)
+	w.csv = csv.NewWriter(w.c.Writer)
+	return w.csv.Write(csvHeader)
+}
+
+func (w *scoreWriter) write(row db.ScoreRow) error {
+	if w.written == 0 {
+		if err := w.start(); err != nil {
+			return err
+		}
+	}
+	w.written++
+
+	var err error
+	if w.json != nil {
+		err = w.json.Encode(scoreRowBody{GuildID: row.GuildID, Username: row.Username, Score: &row.Score})
+	} else {
+		err = w.csv.Write([]string{row.GuildID, row.Username, strconv.Itoa(row.Score)})
+	}
+	if err != nil {
+		return err
+	}
+	if w.written%exportFlushEvery == 0 {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *scoreWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		if err := w.csv.Error(); err != nil {
+			return err
+		}
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// transferGuild returns the guild an export or import is for, from the
+// guild_id query parameter. It must be given, but can be empty for the
+// shared guild that scores from before guilds were tracked are in. With
+// all_guilds=true it is every guild, which only keys with the admin:tables
+// scope can ask for. When it returns false the response has been written.
+func transferGuild(c *gin.Context) (string, bool) {
+	allGuilds, err := boolQuery(c, "all_guilds")
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return "", false
+	}
+	if allGuilds {
+		apiKey, _ := ClientFromContext(c.Request.Context())
+		if !apiKey.HasScope(db.ScopeAdminTables) {
+			writeMissingScope(c, apiKey, db.ScopeAdminTables)
+			return "", false
+		}
+		o11y.AddFieldToTrace(c.Request.Context(), "all-guilds", true)
+		return db.AllGuilds, true
+	}
+	guildID, ok := c.GetQuery("guild_id")
+	if !ok {
+		writeBadRequest(c, "guild_id required, or all_guilds=true with the admin:tables scope")
+		return "", false
+	}
+	return guildID, true
+}
+
+// ExportScoresHandler streams every score in a guild's table as CSV or
+// newline delimited JSON.
+func (a *API) ExportScoresHandler(c *gin.Context) {
+	tableName := c.Query("tablename")
+	if tableName == "" {
+		writeBadRequest(c, "tablename required")
+		return
+	}
+	guildID, ok := transferGuild(c)
+	if !ok {
+		return
+	}
+	a.exportScores(c, tableName, guildID)
+}
+
+// exportScores streams the scores in guildID's tableName in the format the
+// request asks for.
+func (a *API) exportScores(c *gin.Context, tableName string, guildID string) {
+	ctx := c.Request.Context()
+
+	var err error
+	ctx, exportScoresSpan := o11y.StartSpan(ctx, "ExportScoresHandler")
+	defer o11y.End(exportScoresSpan, &err)
+
+	format, err := transferFormat(c, c.GetHeader("Accept"))
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "format", format)
+
+	w := &scoreWriter{c: c, format: format}
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	err = a.store.ExportScores(tableName, guildID, w.write, ctx)
+	o11y.AddFieldToTrace(ctx, "exported", w.written)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		// Once rows have gone out the status can't change, so the export
+		// just stops short.
+		if w.written == 0 {
+			writeError(c, err)
+		}
+		return
+	}
+	if w.written == 0 {
+		if err = w.start(); err != nil {
+			return
+		}
+	}
+	err = w.flush()
+}
+
+// ImportScoresHandler sets the scores of every user in a CSV or newline
+// delimited JSON body. Rows that can't be read or fail validation are
+// reported by line and the rest are still imported. Every row must be for
+// the guild_id query parameter, and rows without a guild go to it.
+func (a *API) ImportScoresHandler(c *gin.Context) {
+	tableName := c.Query("tablename")
+	if tableName == "" {
+		writeBadRequest(c, "tablename required")
+		return
+	}
+	guildID, ok := transferGuild(c)
+	if !ok {
+		return
+	}
+	resp, err := a.importScores(c, tableName, guildID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// importScores imports the scores in the request body into guildID's
+// tableName. A body that can't be read at all is an ErrInvalidInput.
+func (a *API) importScores(c *gin.Context, tableName string, guildID string) (resp importBody, err error) {
+	ctx := c.Request.Context()
+	// When every guild is imported at once, rows without a guild are for
+	// the scores kept before there were guilds.
+	defaultGuild := guildID
+	if guildID == db.AllGuilds {
+		defaultGuild = ""
+	}
+
+	ctx, importScoresSpan := o11y.StartSpan(ctx, "ImportScoresHandler")
+	defer o11y.End(importScoresSpan, &err)
+
+	format, err := transferFormat(c, c.ContentType())
+	if err != nil {
+		return importBody{}, fmt.Errorf("%w: %w", db.ErrInvalidInput, err)
+	}
+	o11y.AddFieldToTrace(ctx, "format", format)
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+	var rows []db.ScoreRow
+	var rowErrs []db.RowError
+	if format == formatNDJSON {
+		rows, rowErrs, err = readNDJSONScores(body, defaultGuild)
+	} else {
+		rows, rowErrs, err = readCSVScores(body, defaultGuild)
+	}
+	if err != nil {
+		return importBody{}, fmt.Errorf("%w: %w", db.ErrInvalidInput, err)
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	result, err := a.store.ImportScores(tableName, guildID, rows, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		return importBody{}, err
+	}
+
+	rowErrs = append(rowErrs, result.Errors...)
+	sort.SliceStable(rowErrs, func(i, j int) bool {
+		return rowErrs[i].Line < rowErrs[j].Line
+	})
+	resp = importBody{Table: tableName, Imported: result.Imported, Errors: make([]rowErrorBody, 0, len(rowErrs))}
+	for _, rowErr := range rowErrs {
+		resp.Errors = append(resp.Errors, rowErrorBody{Line: rowErr.Line, Error: rowErr.Err.Error()})
+	}
+	o11y.AddFieldToTrace(ctx, "imported", resp.Imported)
+	o11y.AddFieldToTrace(ctx, "skipped", len(resp.Errors))
+	return resp, nil
+}
+
+// readCSVScores reads rows under a header naming the username and score
+// columns, and optionally guild_id, in any order.
+func readCSVScores(r io.Reader, guildID string) ([]db.ScoreRow, []db.RowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("there was an error reading the header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	usernameCol, ok := columns["username"]
+	if !ok {
+		return nil, nil, fmt.Errorf("the header must have a username column")
+	}
+	scoreCol, ok := columns["score"]
+	if !ok {
+		return nil, nil, fmt.Errorf("the header must have a score column")
+	}
+	guildCol, hasGuild := columns["guild_id"]
+
+	var rows []db.ScoreRow
+	var rowErrs []db.RowError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrs = append(rowErrs, db.RowError{Line: parseErr.Line, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("there was an error reading the scores: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if usernameCol >= len(record) || scoreCol >= len(record) {
+			rowErrs = append(rowErrs, db.RowError{Line: line, Err: fmt.Errorf("expected %d fields, got %d", len(header), len(record))})
+			continue
+		}
+		score, err := strconv.Atoi(strings.TrimSpace(record[scoreCol]))
+		if err != nil {
+			rowErrs = append(rowErrs, db.RowError{Line: line, Err: fmt.Errorf("score must be a number, got %q", record[scoreCol])})
+			continue
+		}
+		row := db.ScoreRow{Line: line, GuildID: guildID, Username: record[usernameCol], Score: score}
+		if hasGuild && guildCol < len(record) && record[guildCol] != "" {
+			row.GuildID = record[guildCol]
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrs, nil
+}
+
+// readNDJSONScores reads one JSON object per line. Blank lines are skipped.
+func readNDJSONScores(r io.Reader, guildID string) ([]db.ScoreRow, []db.RowError, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows []db.ScoreRow
+	var rowErrs []db.RowError
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var body scoreRowBody
+		if err := json.Unmarshal([]byte(text), &body); err != nil {
+			rowErrs = append(rowErrs, db.RowError{Line: line, Err: err})
+			continue
+		}
+		if body.Score == nil {
+			rowErrs = append(rowErrs, db.RowError{Line: line, Err: errors.New("score is required")})
+			continue
+		}
+		row := db.ScoreRow{Line: line, GuildID: guildID, Username: body.Username, Score: *body.Score}
+		if body.GuildID != "" {
+			row.GuildID = body.GuildID
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("there was an error reading the scores: %w", err)
+	}
+	return rows, rowErrs, nil
+}
@@ -0,0 +1,98 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+)
+
+type userBody struct {
+	UserID string         `json:"user_id"`
+	Name   string         `json:"username"`
+	Names  []userNameBody `json:"names"`
+}
+
+type userNameBody struct {
+	Name   string    `json:"username"`
+	SeenAt time.Time `json:"seen_at"`
+}
+
+type mergeBody struct {
+	Merged   string `json:"merged"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+// identifyUser ties username to their Discord user ID before a request
+// reads or changes their score, so renamed users keep their score. Requests
+// without a user ID are left to go by username alone.
+func (a *API) identifyUser(ctx context.Context, tableName string, guildID string, discordID string, username string) error {
+	if discordID == "" {
+		return nil
+	}
+	o11y.AddFieldToTrace(ctx, "user-id", discordID)
+	_, err := a.store.IdentifyUser(tableName, guildID, discordID, username, ctx)
+	return err
+}
+
+// GetUserHandler returns a Discord user's current name and every name they
+// have been seen with.
+func (a *API) GetUserHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	discordID := c.Query("user_id")
+
+	var err error
+	ctx, getUserHandlerSpan := o11y.StartSpan(ctx, "GetUserHandler")
+	defer o11y.End(getUserHandlerSpan, &err)
+
+	if discordID == "" {
+		writeBadRequest(c, "user_id required")
+		return
+	}
+
+	user, err := a.store.GetUser(discordID, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserBody(user))
+}
+
+func newUserBody(user db.User) userBody {
+	names := make([]userNameBody, 0, len(user.Names))
+	for _, name := range user.Names {
+		names = append(names, userNameBody{Name: name.Name, SeenAt: name.SeenAt})
+	}
+	return userBody{UserID: user.DiscordID, Name: user.Name, Names: names}
+}
+
+// MergeUsersHandler folds one user's score into another's, for users a
+// rename split in two before they were identified by user ID.
+func (a *API) MergeUsersHandler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var requestBody requestBody
+	err := c.BindJSON(&requestBody)
+	ctx, mergeUsersSpan := o11y.StartSpan(ctx, "MergeUsersHandler")
+	defer o11y.End(mergeUsersSpan, &err)
+
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "merge-users", requestBody)
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", requestBody.GuildID)
+	score, err := a.store.MergeUsers(requestBody.TableName, requestBody.GuildID, requestBody.From, requestBody.Into, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mergeBody{Merged: requestBody.From, Username: requestBody.Into, Score: score})
+}
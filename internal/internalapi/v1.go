@@ -0,0 +1,635 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/circleci/ex/o11y"
+	"github.com/gin-gonic/gin"
+	"github.com/imlogang/api-service/internal/db"
+	"github.com/imlogang/api-service/internal/games"
+)
+
+// The /api/v1 routes are the same API as /api/private laid out by resource.
+// Tables, users and rounds are named in the path, the guild a table is read
+// in is the guild_id query parameter, and every JSON response is a dataBody
+// or an errorBody.
+
+// privateDeprecatedAt is when /api/private was deprecated in favour of
+// /api/v1.
+var privateDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// deprecated marks responses from /api/private as deprecated, pointing at
+// /api/v1, and notes on the trace which clients still use it.
+func (a *API) deprecated(c *gin.Context) {
+	c.Header("Deprecation", fmt.Sprintf("@%d", privateDeprecatedAt.Unix()))
+	c.Header("Link", `</api/v1>; rel="successor-version"`)
+	o11y.AddFieldToTrace(c.Request.Context(), "deprecated-route", true)
+	c.Next()
+}
+
+func (a *API) v1Routes(v1 *gin.RouterGroup) {
+	v1.GET("/tables", a.requireScope(db.ScopeReadLeaderboard), a.ListTablesV1Handler)
+	v1.POST("/tables", a.requireScope(db.ScopeAdminTables), a.requireAdmin, a.CreateTableV1Handler)
+	v1.DELETE("/tables/:table", a.requireScope(db.ScopeAdminTables), a.requireAdmin, a.ArchiveTableV1Handler)
+	v1.GET("/tables/:table/leaderboard", a.requireScope(db.ScopeReadLeaderboard), a.LeaderboardV1Handler)
+	v1.GET("/tables/:table/scores", a.requireScope(db.ScopeReadLeaderboard), a.ExportScoresV1Handler)
+	v1.POST("/tables/:table/scores", a.requireScope(db.ScopeWriteScore), a.ImportScoresV1Handler)
+	v1.GET("/tables/:table/answer", a.requireScope(db.ScopeGamePlay), a.AnswerV1Handler)
+	v1.PUT("/tables/:table/users/:user", a.requireScope(db.ScopeWriteScore), a.AddUserV1Handler)
+	v1.GET("/tables/:table/users/:user/score", a.requireScope(db.ScopeReadLeaderboard), a.GetScoreV1Handler)
+	v1.PATCH("/tables/:table/users/:user/score", a.requireScope(db.ScopeWriteScore), a.ChangeScoreV1Handler)
+	v1.GET("/tables/:table/users/:user/rank", a.requireScope(db.ScopeReadLeaderboard), a.RankV1Handler)
+	v1.GET("/tables/:table/users/:user/history", a.requireScope(db.ScopeReadLeaderboard), a.ScoreHistoryV1Handler)
+	v1.POST("/tables/:table/users/:user/merge", a.requireScope(db.ScopeWriteScore), a.MergeUsersV1Handler)
+	v1.GET("/users/:user_id", a.requireScope(db.ScopeReadLeaderboard), a.GetUserV1Handler)
+	v1.GET("/pokemon", a.requireScope(db.ScopeGamePlay), a.PokemonV1Handler)
+	v1.POST("/guilds/:guild/channels/:channel/round", a.requireScope(db.ScopeGamePlay), a.StartRoundV1Handler)
+	v1.POST("/guilds/:guild/channels/:channel/round/guesses", a.requireScope(db.ScopeGamePlay), a.GuessV1Handler)
+	v1.POST("/guilds/:guild/channels/:channel/round/hints", a.requireScope(db.ScopeGamePlay), a.HintV1Handler)
+}
+
+// dataBody is the envelope every successful /api/v1 JSON response is
+// written with.
+type dataBody[T any] struct {
+	Data T `json:"data"`
+}
+
+func writeData[T any](c *gin.Context, status int, data T) {
+	c.JSON(status, dataBody[T]{Data: data})
+}
+
+type tablesBody struct {
+	Tables []string `json:"tables"`
+}
+
+type tableBody struct {
+	Table string `json:"table"`
+}
+
+type tableUserBody struct {
+	Table    string `json:"table"`
+	GuildID  string `json:"guild_id,omitempty"`
+	Username string `json:"username"`
+	UserID   string `json:"user_id,omitempty"`
+}
+
+type userScoreBody struct {
+	Table    string `json:"table"`
+	GuildID  string `json:"guild_id,omitempty"`
+	Username string `json:"username"`
+	Score    int    `json:"score"`
+}
+
+type scoreChangeBody struct {
+	Table     string     `json:"table"`
+	GuildID   string     `json:"guild_id,omitempty"`
+	Username  string     `json:"username"`
+	Operation db.ScoreOp `json:"operation"`
+	Score     int        `json:"score"`
+}
+
+type answerBody struct {
+	Table    string `json:"table"`
+	GuildID  string `json:"guild_id,omitempty"`
+	Answer   string `json:"answer"`
+	Position int    `json:"position"`
+}
+
+type pokemonBody struct {
+	Name string `json:"name"`
+}
+
+type createTableRequest struct {
+	Table  string `json:"table"`
+	DryRun bool   `json:"dry_run"`
+}
+
+type addUserRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type changeScoreRequest struct {
+	Operation  string `json:"operation"`
+	Amount     int    `json:"amount"`
+	Reason     string `json:"reason"`
+	OnBehalfOf string `json:"on_behalf_of"`
+	UserID     string `json:"user_id"`
+}
+
+type mergeUsersRequest struct {
+	From string `json:"from"`
+}
+
+type startRoundRequest struct {
+	Generation string `json:"generation"`
+	Legendary  string `json:"legendary"`
+	Difficulty string `json:"difficulty"`
+}
+
+type guessRequest struct {
+	Username string `json:"username"`
+	UserID   string `json:"user_id"`
+	Guess    string `json:"guess"`
+}
+
+func (a *API) ListTablesV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	tables, err := a.store.ListTables(ctx)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if tables == nil {
+		tables = []string{}
+	}
+	writeData(c, http.StatusOK, tablesBody{Tables: tables})
+}
+
+// CreateTableV1Handler adds a table, or with dry_run set reports whether it
+// would.
+func (a *API) CreateTableV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	var request createTableRequest
+	err := c.BindJSON(&request)
+	ctx, createTableSpan := o11y.StartSpan(ctx, "CreateTableV1Handler")
+	defer o11y.End(createTableSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if !a.checkManaged(c, request.Table) {
+		return
+	}
+	if request.DryRun {
+		summary, err := a.store.DescribeTable(request.Table, ctx)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		action := actionCreate
+		if summary.Exists {
+			action = actionNone
+		}
+		writeData(c, http.StatusOK, newDryRunBody(action, summary))
+		return
+	}
+
+	_, err = a.store.CreateTable(request.Table, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusCreated, tableBody{Table: request.Table})
+}
+
+// ArchiveTableV1Handler archives a table, or with the dry_run query
+// parameter set reports what would be archived.
+func (a *API) ArchiveTableV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+
+	var err error
+	ctx, archiveTableSpan := o11y.StartSpan(ctx, "ArchiveTableV1Handler")
+	defer o11y.End(archiveTableSpan, &err)
+
+	dryRun, err := boolQuery(c, "dry_run")
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	if !a.checkManaged(c, tableName) {
+		return
+	}
+	if dryRun {
+		summary, err := a.store.DescribeTable(tableName, ctx)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		if !summary.Exists {
+			writeError(c, fmt.Errorf("the table %s does not exist: %w", tableName, db.ErrNotFound))
+			return
+		}
+		writeData(c, http.StatusOK, newDryRunBody(actionArchive, summary))
+		return
+	}
+
+	_, err = a.store.DeleteTable(tableName, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, tableBody{Table: tableName})
+}
+
+// boolQuery reads an optional true or false query parameter.
+func boolQuery(c *gin.Context, name string) (bool, error) {
+	v := c.Query(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false, got %q", name, v)
+	}
+	return b, nil
+}
+
+func (a *API) LeaderboardV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+
+	var err error
+	ctx, leaderboardSpan := o11y.StartSpan(ctx, "LeaderboardV1Handler")
+	defer o11y.End(leaderboardSpan, &err)
+
+	period, page, err := leaderboardPage(c, time.Now())
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "period", period)
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+
+	leaderboard, err := a.store.Leaderboard(tableName, guildID, page, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, newLeaderboardBody(tableName, guildID, period, leaderboard))
+}
+
+// ExportScoresV1Handler streams a guild's scores in a table. Like the
+// /api/private export it is CSV or newline delimited JSON rather than a
+// dataBody.
+func (a *API) ExportScoresV1Handler(c *gin.Context) {
+	guildID, ok := transferGuild(c)
+	if !ok {
+		return
+	}
+	a.exportScores(c, c.Param("table"), guildID)
+}
+
+func (a *API) ImportScoresV1Handler(c *gin.Context) {
+	guildID, ok := transferGuild(c)
+	if !ok {
+		return
+	}
+	resp, err := a.importScores(c, c.Param("table"), guildID)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, resp)
+}
+
+// AnswerV1Handler returns the answer and position last stored for a guild.
+func (a *API) AnswerV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+
+	var err error
+	ctx, answerSpan := o11y.StartSpan(ctx, "AnswerV1Handler")
+	defer o11y.End(answerSpan, &err)
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	answer, err := a.store.ReadAnswerFromDB(tableName, guildID, "answer", ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	position, err := a.store.ReadAnswerFromDB(tableName, guildID, "position", ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	body := answerBody{Table: tableName, GuildID: guildID, Answer: answer}
+	body.Position, err = strconv.Atoi(position)
+	if err != nil {
+		writeError(c, fmt.Errorf("the stored position %q is not a number: %w", position, err))
+		return
+	}
+	writeData(c, http.StatusOK, body)
+}
+
+// AddUserV1Handler adds a user to a table if they are new. With a user_id
+// in the body they are tied to that Discord user.
+func (a *API) AddUserV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	username := c.Param("user")
+
+	var request addUserRequest
+	var err error
+	if c.Request.ContentLength != 0 {
+		err = c.BindJSON(&request)
+	}
+	ctx, addUserSpan := o11y.StartSpan(ctx, "AddUserV1Handler")
+	defer o11y.End(addUserSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	if request.UserID != "" {
+		_, err = a.store.IdentifyUser(tableName, guildID, request.UserID, username, ctx)
+	} else {
+		_, err = a.store.UpdateTableWithUser(tableName, guildID, username, ctx)
+	}
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, tableUserBody{Table: tableName, GuildID: guildID, Username: username, UserID: request.UserID})
+}
+
+func (a *API) GetScoreV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	username := c.Param("user")
+
+	var err error
+	ctx, getScoreSpan := o11y.StartSpan(ctx, "GetScoreV1Handler")
+	defer o11y.End(getScoreSpan, &err)
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	err = a.identifyUser(ctx, tableName, guildID, c.Query("user_id"), username)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	score, err := a.store.GetCurrentScore(tableName, guildID, username, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, userScoreBody{Table: tableName, GuildID: guildID, Username: username, Score: score})
+}
+
+// ChangeScoreV1Handler increments, decrements or sets a user's score, adding
+// them if they are new. The operation is required.
+func (a *API) ChangeScoreV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	username := c.Param("user")
+
+	var request changeScoreRequest
+	err := c.BindJSON(&request)
+	ctx, changeScoreSpan := o11y.StartSpan(ctx, "ChangeScoreV1Handler")
+	defer o11y.End(changeScoreSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	op, err := db.ParseScoreOp(request.Operation)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "operation", op)
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+
+	err = a.identifyUser(ctx, tableName, guildID, request.UserID, username)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	apiKey, _ := ClientFromContext(ctx)
+	score, err := a.store.ChangeScore(tableName, guildID, username, db.ScoreChange{
+		Op:         op,
+		Amount:     request.Amount,
+		Reason:     request.Reason,
+		Actor:      apiKey.Client,
+		OnBehalfOf: request.OnBehalfOf,
+	}, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, scoreChangeBody{Table: tableName, GuildID: guildID, Username: username, Operation: op, Score: score})
+}
+
+func (a *API) RankV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	username := c.Param("user")
+
+	var err error
+	ctx, rankSpan := o11y.StartSpan(ctx, "RankV1Handler")
+	defer o11y.End(rankSpan, &err)
+
+	window, err := standingWindow(c)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	standing, err := a.store.Standing(tableName, guildID, username, window, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "rank", standing.Rank)
+	writeData(c, http.StatusOK, newStandingBody(standing))
+}
+
+func (a *API) ScoreHistoryV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	username := c.Param("user")
+
+	var err error
+	ctx, scoreHistorySpan := o11y.StartSpan(ctx, "ScoreHistoryV1Handler")
+	defer o11y.End(scoreHistorySpan, &err)
+
+	page, err := pageQuery(c)
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	history, err := a.store.ScoreHistory(tableName, guildID, username, page, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "score-changes", history.Total)
+	writeData(c, http.StatusOK, newScoreHistoryBody(tableName, guildID, username, history))
+}
+
+// MergeUsersV1Handler folds the score of the user named in the body into
+// the user in the path.
+func (a *API) MergeUsersV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	tableName := c.Param("table")
+	guildID := c.Query("guild_id")
+	into := c.Param("user")
+
+	var request mergeUsersRequest
+	err := c.BindJSON(&request)
+	ctx, mergeUsersSpan := o11y.StartSpan(ctx, "MergeUsersV1Handler")
+	defer o11y.End(mergeUsersSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	o11y.AddFieldToTrace(ctx, "guild-id", guildID)
+	score, err := a.store.MergeUsers(tableName, guildID, request.From, into, ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, mergeBody{Merged: request.From, Username: into, Score: score})
+}
+
+func (a *API) GetUserV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var err error
+	ctx, getUserSpan := o11y.StartSpan(ctx, "GetUserV1Handler")
+	defer o11y.End(getUserSpan, &err)
+
+	user, err := a.store.GetUser(c.Param("user_id"), ctx)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, newUserBody(user))
+}
+
+func (a *API) PokemonV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var err error
+	ctx, pokemonSpan := o11y.StartSpan(ctx, "PokemonV1Handler")
+	defer o11y.End(pokemonSpan, &err)
+
+	filter, err := games.ParseFilter(c.Query("generation"), c.Query("legendary"), c.Query("difficulty"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "filter", filter)
+
+	pokemon, err := games.GetPokemon(ctx, a.catalog, filter)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	writeData(c, http.StatusOK, pokemonBody{Name: pokemon})
+}
+
+func (a *API) StartRoundV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	guildID := c.Param("guild")
+	channelID := c.Param("channel")
+
+	var request startRoundRequest
+	var err error
+	if c.Request.ContentLength != 0 {
+		err = c.BindJSON(&request)
+	}
+	ctx, startRoundSpan := o11y.StartSpan(ctx, "StartRoundV1Handler")
+	defer o11y.End(startRoundSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+	filter, err := games.ParseFilter(request.Generation, request.Legendary, request.Difficulty)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	round, err := a.game.Start(ctx, guildID, channelID, filter)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "round-id", round.ID)
+	writeData(c, http.StatusCreated, newRoundBody(round))
+}
+
+func (a *API) GuessV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+	guildID := c.Param("guild")
+	channelID := c.Param("channel")
+
+	var request guessRequest
+	err := c.BindJSON(&request)
+	ctx, guessSpan := o11y.StartSpan(ctx, "GuessV1Handler")
+	defer o11y.End(guessSpan, &err)
+
+	if err != nil {
+		writeBadRequest(c, err.Error())
+		return
+	}
+
+	err = a.identifyUser(ctx, db.PokemonScoresTable, guildID, request.UserID, request.Username)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "db-error", err)
+		writeError(c, err)
+		return
+	}
+	result, err := a.game.Guess(ctx, guildID, channelID, request.Username, request.Guess)
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "correct", result.Correct)
+	writeData(c, http.StatusOK, newGuessBody(result))
+}
+
+func (a *API) HintV1Handler(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var err error
+	ctx, hintSpan := o11y.StartSpan(ctx, "HintV1Handler")
+	defer o11y.End(hintSpan, &err)
+
+	result, err := a.game.Hint(ctx, c.Param("guild"), c.Param("channel"))
+	if err != nil {
+		o11y.AddFieldToTrace(ctx, "game-error", err)
+		writeError(c, err)
+		return
+	}
+	o11y.AddFieldToTrace(ctx, "hints-used", len(result.Hints))
+	writeData(c, http.StatusOK, newHintBody(result))
+}
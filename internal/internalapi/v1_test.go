@@ -0,0 +1,171 @@
+package httpapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/circleci/ex/testing/testcontext"
+	"github.com/imlogang/api-service/internal/db"
+	"gotest.tools/v3/assert"
+	"gotest.tools/v3/assert/cmp"
+)
+
+// serveV1 sends a request to the test API and returns its response.
+func serveV1(t *testing.T, a *API, method string, target string, request any) *httptest.ResponseRecorder {
+	t.Helper()
+	var body io.Reader
+	if request != nil {
+		b, err := json.Marshal(request)
+		assert.NilError(t, err)
+		body = bytes.NewReader(b)
+	}
+	req := newTestRequest(method, "http://localhost:8080"+target, body)
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	w := httptest.NewRecorder()
+	a.Router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeData decodes a dataBody response into data.
+func decodeData[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+	t.Helper()
+	var resp dataBody[T]
+	assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+	return resp.Data
+}
+
+func TestAPI_V1Scores(t *testing.T) {
+	ctx := testcontext.Background()
+	a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{"ash": 9, "misty": 7})))
+	assert.NilError(t, err)
+
+	w := serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/users/ash/score", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[userScoreBody](t, w), userScoreBody{Table: "pokemon_scores", Username: "ash", Score: 9}))
+
+	w = serveV1(t, a, "PATCH", "/api/v1/tables/pokemon_scores/users/misty/score", changeScoreRequest{Operation: "increment", Amount: 5})
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[scoreChangeBody](t, w),
+		scoreChangeBody{Table: "pokemon_scores", Username: "misty", Operation: db.ScoreIncrement, Score: 12}))
+
+	w = serveV1(t, a, "PATCH", "/api/v1/tables/pokemon_scores/users/misty/score", changeScoreRequest{Amount: 5})
+	assert.Check(t, cmp.Equal(w.Code, 400), w.Body.String())
+
+	w = serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/leaderboard?limit=1", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[leaderboardBody](t, w), leaderboardBody{
+		Table:      "pokemon_scores",
+		Period:     db.PeriodAllTime,
+		Entries:    []leaderboardEntry{{Rank: 1, Username: "misty", Score: 12}},
+		Total:      2,
+		Limit:      1,
+		NextOffset: 1,
+	}))
+
+	w = serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/users/ash/rank?window=1", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Equal(decodeData[standingBody](t, w).Rank, 2))
+
+	w = serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/users/misty/history", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	history := decodeData[scoreHistoryBody](t, w)
+	assert.Assert(t, len(history.Entries) > 0)
+	assert.Check(t, cmp.Equal(history.Entries[0].Delta, 5))
+
+	w = serveV1(t, a, "PUT", "/api/v1/tables/pokemon_scores/users/brock", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	w = serveV1(t, a, "POST", "/api/v1/tables/pokemon_scores/users/ash/merge", mergeUsersRequest{From: "brock"})
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[mergeBody](t, w), mergeBody{Merged: "brock", Username: "ash", Score: 9}))
+
+	// Errors keep the same body as everywhere else.
+	w = serveV1(t, a, "GET", "/api/v1/tables/no_such_table/users/ash/score", nil)
+	assert.Check(t, cmp.Equal(w.Code, 404), w.Body.String())
+	var resp errorBody
+	assert.NilError(t, json.NewDecoder(w.Body).Decode(&resp))
+	assert.Check(t, cmp.Equal(resp.Code, codeNotFound))
+}
+
+func TestAPI_V1Tables(t *testing.T) {
+	ctx := testcontext.Background()
+	a, err := New(ctx, testOptions(t, newTestStore(t, nil)))
+	assert.NilError(t, err)
+
+	w := serveV1(t, a, "POST", "/api/v1/tables", createTableRequest{Table: "random_table", DryRun: true})
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Equal(decodeData[dryRunBody](t, w).Action, actionCreate))
+
+	w = serveV1(t, a, "POST", "/api/v1/tables", createTableRequest{Table: "random_table"})
+	assert.Assert(t, cmp.Equal(w.Code, 201), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[tableBody](t, w), tableBody{Table: "random_table"}))
+
+	w = serveV1(t, a, "POST", "/api/v1/tables", createTableRequest{Table: "not_managed"})
+	assert.Check(t, cmp.Equal(w.Code, 403), w.Body.String())
+
+	w = serveV1(t, a, "GET", "/api/v1/tables", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Contains(decodeData[tablesBody](t, w).Tables, "random_table"))
+
+	w = serveV1(t, a, "DELETE", "/api/v1/tables/random_table?dry_run=true", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Equal(decodeData[dryRunBody](t, w).Action, actionArchive))
+
+	w = serveV1(t, a, "DELETE", "/api/v1/tables/random_table", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[tableBody](t, w), tableBody{Table: "random_table"}))
+}
+
+func TestAPI_V1Game(t *testing.T) {
+	ctx := testcontext.Background()
+	store := newGuildTestStore(t, "guild-1", nil)
+	a, err := New(ctx, testOptions(t, store))
+	assert.NilError(t, err)
+
+	w := serveV1(t, a, "GET", "/api/v1/pokemon", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[pokemonBody](t, w), pokemonBody{Name: "pikachu"}))
+
+	w = serveV1(t, a, "POST", "/api/v1/guilds/guild-1/channels/channel-1/round", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 201), w.Body.String())
+	assert.Check(t, decodeData[roundBody](t, w).RoundID != 0)
+
+	w = serveV1(t, a, "POST", "/api/v1/guilds/guild-1/channels/channel-1/round/hints", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Len(decodeData[hintBody](t, w).Hints, 1))
+
+	w = serveV1(t, a, "POST", "/api/v1/guilds/guild-1/channels/channel-1/round/guesses", guessRequest{Username: "ash", Guess: "pikachu"})
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, decodeData[guessBody](t, w).Correct)
+
+	_, err = store.PutAnswerInDB("pokemon_scores", "guild-1", "pikachu", 25, ctx)
+	assert.NilError(t, err)
+	w = serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/answer?guild_id=guild-1", nil)
+	assert.Assert(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.DeepEqual(decodeData[answerBody](t, w),
+		answerBody{Table: "pokemon_scores", GuildID: "guild-1", Answer: "pikachu", Position: 25}))
+}
+
+func TestAPI_Deprecation(t *testing.T) {
+	ctx := testcontext.Background()
+	a, err := New(ctx, testOptions(t, newTestStore(t, map[string]int{"ash": 9})))
+	assert.NilError(t, err)
+
+	w := serveV1(t, a, "GET", "/api/private/get_current_score?tablename=pokemon_scores&username=ash", nil)
+	assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Equal(w.Header().Get("Deprecation"), "@1792281600"))
+	assert.Check(t, cmp.Equal(w.Header().Get("Link"), `</api/v1>; rel="successor-version"`))
+
+	w = serveV1(t, a, "GET", "/api/v1/tables/pokemon_scores/users/ash/score", nil)
+	assert.Check(t, cmp.Equal(w.Code, 200), w.Body.String())
+	assert.Check(t, cmp.Equal(w.Header().Get("Deprecation"), ""))
+
+	// Unauthenticated requests to /api/private are still told it is
+	// deprecated.
+	w = httptest.NewRecorder()
+	a.Router.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost:8080/api/private/hello", nil))
+	assert.Check(t, cmp.Equal(w.Code, 401), w.Body.String())
+	assert.Check(t, cmp.Equal(w.Header().Get("Deprecation"), "@1792281600"))
+}